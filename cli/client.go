@@ -12,19 +12,28 @@ import (
 	"github.com/wendigo/gcp-builder/notifications"
 	"github.com/wendigo/gcp-builder/platforms"
 	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/release"
+	"github.com/wendigo/gcp-builder/report"
+	"github.com/wendigo/gcp-builder/scan"
 	"io/ioutil"
 	"log"
 	"os"
 	"reflect"
+	"strings"
 )
 
 type Client struct {
 	config   *config.Args
+	project  *project.Configuration
 	context  *kubernetes.Context
 	logger   *log.Logger
 	gcloud   *gcloud.Client
 	platform platforms.Platform
 	notifier notifications.NotificationsProvider
+	params   context.Params
+	report   *report.Report
+	// containers is shared by steps so images pushed by remote builders are known to later steps
+	containers *containers.Client
 }
 
 func New(config *config.Args, cliVersion string) (*Client, error) {
@@ -40,6 +49,15 @@ func New(config *config.Args, cliVersion string) (*Client, error) {
 		return nil, err
 	}
 
+	// release fixes the tagging so it must not depend on the version detected from tags
+	if isRelease(config.Steps) {
+		return &Client{
+			config:  config,
+			project: prj,
+			logger:  logger,
+		}, nil
+	}
+
 	platform, err := platforms.Detect()
 	if err != nil {
 		return nil, err
@@ -55,19 +73,43 @@ func New(config *config.Args, cliVersion string) (*Client, error) {
 		return nil, err
 	}
 
-	notifier := notifications.Get(context.From(ctx, platform))
+	number, _ := platform.CurrentBuildNumber()
+
+	ctx.Commit = platform.CurrentCommitDetails()
+	ctx.Build = kubernetes.Build{
+		Platform:   platform.Name(),
+		Branch:     platform.CurrentBranch(),
+		Tag:        strings.Join(platform.CurrentTags(), ", "),
+		Number:     number,
+		Url:        platform.BuildUrl(),
+		Repository: platform.RepositoryUrl(),
+	}
+
+	params := context.From(ctx, platform)
+	notifier := notifications.Get(params, prj.Notifications.Templates)
 
 	return &Client{
 		config:   config,
+		project:  prj,
 		context:  ctx,
 		gcloud:   gcloud.NewClient(config.Update),
 		platform: platform,
 		logger:   logger,
 		notifier: notifier,
+		params:   params,
+		report:   report.New(prj.Project.FullName(), ctx.CurrentEnvironment.Name, version.String(), ctx.Commit.Hash),
 	}, nil
 }
 
+func isRelease(steps []string) bool {
+	return reflect.DeepEqual(steps, []string{"release"})
+}
+
 func (c *Client) Run() error {
+	if isRelease(c.config.Steps) {
+		return c.release()
+	}
+
 	if err := c.init(); err != nil {
 		return err
 	}
@@ -77,6 +119,8 @@ func (c *Client) Run() error {
 			"info",
 			"auth",
 			"build",
+			"test-images",
+			"scan",
 			"push",
 			"deploy-config",
 			"validate-config",
@@ -89,11 +133,32 @@ func (c *Client) Run() error {
 
 	err := c.executeSteps(c.config.Steps)
 
+	// staged images are only promoted within the run
+	if c.containers != nil {
+		c.containers.RemoveStagingTags()
+	}
+
 	c.notifier.OnReleaseCompleted(c.config.Steps, err)
 
+	c.writeReport(err)
+
 	return err
 }
 
+func (c *Client) writeReport(err error) {
+	if c.config.Report == "" {
+		return
+	}
+
+	c.report.Finish(c.config.Steps, err)
+
+	if err := c.report.Write(c.config.Report); err != nil {
+		c.logger.Printf("Could not write run report %s: %s", c.config.Report, err)
+	} else {
+		c.logger.Printf("Run report written to %s", c.config.Report)
+	}
+}
+
 func (c *Client) executeSteps(steps []string) error {
 	for _, step := range steps {
 		switch step {
@@ -102,9 +167,17 @@ func (c *Client) executeSteps(steps []string) error {
 			c.logger.Printf("CI/CD platform info:")
 			c.logger.Printf("\tName: %s", c.platform.Name())
 			c.logger.Printf("\tCurrent branch: %s", c.platform.CurrentBranch())
-			c.logger.Printf("\tCurrent tag: %s", c.platform.CurrentTag())
+			c.logger.Printf("\tCurrent tags: %s", strings.Join(c.platform.CurrentTags(), ", "))
 			c.logger.Printf("\tCurrent commit: %s", c.platform.CurrentCommit())
-			c.logger.Printf("\tCurrent build number: %s", c.platform.CurrentBuildNumber())
+
+			if number, err := c.platform.CurrentBuildNumber(); err != nil {
+				c.logger.Printf("\tCurrent build number: n/a (%s)", err)
+			} else {
+				c.logger.Printf("\tCurrent build number: %s", number)
+			}
+
+			c.logger.Printf("\tCommit author: %s", c.context.Commit.Author)
+			c.logger.Printf("\tCommit subject: %s", c.context.Commit.Subject)
 
 			c.logger.Printf("Project info:")
 			c.logger.Printf("\tName: %s", c.context.Config.Project.Name)
@@ -118,17 +191,33 @@ func (c *Client) executeSteps(steps []string) error {
 			c.logger.Printf("\tName: %s", env.Name)
 			c.logger.Printf("\tProject: %s", env.Cloud.Project)
 			c.logger.Printf("\tRegistry: %s", env.Cloud.Registry)
+
+			for _, image := range c.context.Images() {
+				c.logger.Printf("\tImage %s registry: %s", image.Name, c.context.Registry(image.Name))
+			}
 			c.logger.Printf("\tCluster: %s", env.Kubernetes.Cluster)
 			c.logger.Printf("\tZone: %s", env.Kubernetes.Zone)
 		case "auth":
 			if err := c.authorize(); err != nil {
 				return err
 			}
+
+			c.resolveChangelog()
 		case "build":
 			if err := c.buildContainers(); err != nil {
 				return err
 			}
 
+		case "test-images":
+			if err := c.testContainers(); err != nil {
+				return err
+			}
+
+		case "scan":
+			if err := c.scanContainers(); err != nil {
+				return err
+			}
+
 		case "push":
 			if err := c.pushContainers(); err != nil {
 				return err
@@ -177,6 +266,96 @@ func (c *Client) authorize() error {
 		return err2
 	}
 
+	client, err := c.containersClient()
+	if err != nil {
+		return err
+	}
+
+	return client.Login(c.context)
+}
+
+// deployedCommit finds the commit currently deployed to the environment namespace, from
+// build values of the Helm release or annotations of the project deployments.
+func (c *Client) deployedCommit() (string, error) {
+	if c.context.CurrentEnvironment.Kubernetes.Helm != nil {
+		out, err := c.gcloud.CaptureCommand("helm", c.namespaced([]string{"get", "values", c.context.HelmRelease(), "--output", "json"}))
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("HelmReleaseNotFound(%s): %s", c.context.HelmRelease(), strings.TrimSpace(string(out))))
+		}
+
+		return kubernetes.HelmDeployedCommit(out)
+	}
+
+	out, err := c.gcloud.CaptureCommand("kubectl", c.namespaced([]string{"get", "deployments", "--selector", c.context.Selector(), "-o", "json"}))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("DeploymentsNotFound(%s): %s", c.context.Selector(), strings.TrimSpace(string(out))))
+	}
+
+	return kubernetes.DeployedCommit(out, c.context.Config.Project.FullName())
+}
+
+// namespaced adds the environment namespace to kubectl and helm arguments.
+func (c *Client) namespaced(args []string) []string {
+	if namespace := c.context.Namespace(); namespace != "" {
+		return append(args, "--namespace", namespace)
+	}
+
+	return args
+}
+
+func (c *Client) resolveChangelog() {
+	deployed, err := c.deployedCommit()
+	if err != nil {
+		c.logger.Printf("Could not determine deployed version: %s", err)
+		return
+	}
+
+	changelog, err := c.platform.Changelog(deployed)
+	if err != nil {
+		c.logger.Printf("Could not generate changelog since %s: %s", deployed, err)
+		return
+	}
+
+	c.context.DeployedCommit = deployed
+	c.context.Changelog = changelog
+	c.params.Refresh(c.context)
+
+	c.logger.Printf("%d commits since deployed commit %s", len(changelog), deployed)
+}
+
+func (c *Client) release() error {
+	repository, err := platforms.NewLocalGitRepositoryPlatform()
+	if err != nil {
+		return err
+	}
+
+	prefix := c.project.Project.VersionPrefix
+
+	next, err := release.Prepare(prefix, repository, c.config.Bump)
+	if err != nil {
+		return err
+	}
+
+	if next.PreviousTag == "" {
+		c.logger.Printf("No previous release with prefix '%s' found", prefix)
+	} else {
+		c.logger.Printf("Previous release: %s", next.PreviousTag)
+	}
+
+	c.logger.Printf("Releasing %s (%s bump, %d commits)", next.Tag, next.Bump, len(next.Commits))
+
+	if err := repository.CreateTag(next.Tag, next.Message()); err != nil {
+		return err
+	}
+
+	if c.config.Push {
+		c.logger.Printf("Pushing tag %s", next.Tag)
+
+		if err := repository.PushTag(next.Tag); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -190,27 +369,53 @@ func (c *Client) init() error {
 	return nil
 }
 
+func (c *Client) containersClient() (*containers.Client, error) {
+	if c.containers == nil {
+		client, err := containers.New(c.gcloud, c.context.CurrentEnvironment.Registries)
+		if err != nil {
+			return nil, err
+		}
+
+		c.containers = client
+	}
+
+	return c.containers, nil
+}
+
 func (c *Client) buildContainers() error {
 
-	client, err := containers.New(c.gcloud)
+	client, err := c.containersClient()
 	if err != nil {
 		return err
 	}
 
 	c.logger.Printf("Building containers")
 
-	for _, image := range c.context.Config.Images {
+	for _, image := range c.context.Images() {
+
+		if skip, err := c.skipPushedRelease(client, image); err != nil {
+			return err
+		} else if skip {
+			continue
+		}
 
 		c.notifier.OnImageBuilding(image)
 
 		if image.Dockerfile == "" {
-			image.Dockerfile = "Dockerfile"
+			image.Dockerfile = project.DefaultDockerfile
 		}
 
-		out, err := client.BuildContainer(c.context, image)
-		c.notifier.OnImageBuilt(image, string(out), err)
+		result, out, err := client.BuildContainer(c.context, image, c.stagesImage(image))
+
+		if err == nil && result.Reused != "" {
+			c.notifier.OnImageReused(image, result.Reused)
+		} else {
+			c.notifier.OnImageBuilt(image, string(out), err)
+		}
 
-		os.Stderr.Write(out)
+		if err == nil {
+			c.recordImage(image, result)
+		}
 
 		if err != nil {
 			c.logger.Printf("Error building container: %s", err)
@@ -221,6 +426,187 @@ func (c *Client) buildContainers() error {
 	return nil
 }
 
+// stagesImage tells whether the image is tested or scanned in this run before it is pushed.
+// Builders pushing on build push such images to a staging tag promoted in the push step.
+func (c *Client) stagesImage(image project.Image) bool {
+	for _, step := range c.config.Steps {
+		if step == "test-images" && image.Tests != nil {
+			return true
+		}
+
+		if step == "scan" && c.context.CurrentEnvironment.Scan != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) recordImage(image project.Image, result containers.Result) {
+	entry := c.report.Image(image.Name)
+	entry.Tag = result.Tag
+	entry.Builder = result.Builder
+	entry.ContentHash = result.ContentHash
+	entry.ReusedFrom = result.Reused
+
+	if len(result.Digests) > 0 {
+		entry.Tags = result.Digests
+	}
+
+	if result.Digest != "" {
+		entry.Digest = result.Digest
+	}
+
+	if len(result.PlatformDigests) > 0 {
+		entry.Platforms = result.PlatformDigests
+	}
+
+	if result.Cache != nil && entry.Cache == nil {
+		entry.Cache = result.Cache
+		c.notifier.OnImageCache(image, *result.Cache)
+	}
+}
+
+// testContainers runs checks of every image with tests, all images are tested before failing.
+func (c *Client) testContainers() error {
+	client, err := c.containersClient()
+	if err != nil {
+		return err
+	}
+
+	c.logger.Printf("Testing containers")
+
+	failed := make([]string, 0)
+
+	for _, image := range c.context.Images() {
+		if image.Tests == nil {
+			continue
+		}
+
+		tests, err := client.TestContainer(c.context, image)
+		c.notifier.OnImageTested(image, tests, err)
+		c.report.Image(image.Name).Tests = tests
+
+		if err != nil {
+			return err
+		}
+
+		passed := true
+
+		for _, test := range tests {
+			if test.Passed {
+				c.logger.Printf("\t%s: %s passed", image.Name, test.Name)
+			} else {
+				c.logger.Printf("\t%s: %s failed: %s", image.Name, test.Name, test.Message)
+				passed = false
+			}
+		}
+
+		if !passed {
+			failed = append(failed, image.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("ImageTestsFailed(%s)", strings.Join(failed, ", ")))
+	}
+
+	return nil
+}
+
+// scanContainers scans every image with the scanner of the environment and fails when any of
+// them has findings at or above the threshold which are not allowed.
+func (c *Client) scanContainers() error {
+	config := c.context.CurrentEnvironment.Scan
+	if config == nil {
+		c.logger.Printf("Scanning is not configured for environment %s, skipping", c.context.CurrentEnvironment.Name)
+		return nil
+	}
+
+	client, err := c.containersClient()
+	if err != nil {
+		return err
+	}
+
+	scanner, err := scan.New(*config)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Printf("Scanning containers with %s", scanner.Name())
+
+	scans := make([]report.Scan, 0)
+	blocked := make([]string, 0)
+
+	for _, image := range c.context.Images() {
+		result, err := c.scanContainer(client, scanner, image)
+		if err != nil {
+			c.notifier.OnImagesScanned(scans, err)
+			return err
+		}
+
+		c.report.Image(image.Name).Scan = &result
+		scans = append(scans, result)
+
+		c.logger.Printf("\t%s: %d findings, %d at or above %s not allowed", image.Name, len(result.Findings), result.Blocking, result.Threshold)
+
+		if result.Blocking > 0 {
+			blocked = append(blocked, image.Name)
+		}
+	}
+
+	if len(blocked) > 0 {
+		err = errors.New(fmt.Sprintf("VulnerabilitiesFound(%s): findings at or above %s severity", strings.Join(blocked, ", "), scans[0].Threshold))
+	}
+
+	c.notifier.OnImagesScanned(scans, err)
+
+	return err
+}
+
+func (c *Client) scanContainer(client *containers.Client, scanner scan.Scanner, image project.Image) (report.Scan, error) {
+	reference, err := client.LocalReference(c.context, image)
+	if err != nil {
+		return report.Scan{}, err
+	}
+
+	findings, err := scanner.Scan(reference)
+	if err != nil {
+		return report.Scan{}, err
+	}
+
+	return scan.Evaluate(image.Name, scanner.Name(), findings, *c.context.CurrentEnvironment.Scan)
+}
+
+// skipPushedRelease checks release images of builders which push on build as they would
+// overwrite the release tag before the push step could verify it.
+func (c *Client) skipPushedRelease(client *containers.Client, image project.Image) (bool, error) {
+	if c.context.Version.IsSnapshot() || c.config.ForceOverwrite {
+		return false, nil
+	}
+
+	pushesOnBuild, err := client.PushesOnBuild(c.context, image)
+	if err != nil || !pushesOnBuild {
+		return false, err
+	}
+
+	tag := c.context.ContainerPath(image.Name)
+
+	pushed, err := client.ReleasePushed(tag)
+	if err != nil || !pushed {
+		return false, err
+	}
+
+	c.logger.Printf("Release image %s was already pushed, skipping build", tag)
+	c.notifier.OnImageBuilt(image, fmt.Sprintf("%s was already pushed", tag), nil)
+
+	if result, pushed := client.Result(tag); pushed {
+		c.recordImage(image, result)
+	}
+
+	return true, nil
+}
+
 func (c *Client) buildDeployment() error {
 	filename, err := c.deploymentFile()
 	if err != nil {
@@ -234,23 +620,36 @@ func (c *Client) buildDeployment() error {
 
 	c.context.ContainersShas = ids
 
-	return c.context.InterpolateConfig(
-		c.context.CurrentEnvironment.Kubernetes.Template,
-		filename,
-	)
+	switch {
+	case c.context.CurrentEnvironment.Kubernetes.Helm != nil:
+		err = c.renderHelmChart(filename)
+	case c.context.CurrentEnvironment.Kubernetes.Kustomize != nil:
+		err = c.renderKustomization(filename)
+	default:
+		err = c.context.RenderManifests(
+			c.context.CurrentEnvironment.Kubernetes.Template,
+			filename,
+		)
+	}
+
+	if err != nil || c.context.CurrentEnvironment.Kubernetes.Kustomize != nil {
+		return err
+	}
+
+	return c.context.AppendConfigMaps(filename)
 }
 
 func (c *Client) gatherImagesShas() (map[string]string, error) {
 	images := make(map[string]string, 0)
 
-	client, err := containers.New(c.gcloud)
+	client, err := c.containersClient()
 	if err != nil {
 		return images, err
 	}
 
 	c.logger.Printf("Checking images SHAs...")
 
-	for _, image := range c.context.Config.Images {
+	for _, image := range c.context.Images() {
 		id, err := client.ContainerSha256(c.context, image)
 		if err != nil {
 			return images, err
@@ -291,7 +690,18 @@ func (c *Client) deploy() error {
 	}
 
 	c.notifier.OnDeploying()
-	out, err2 := c.gcloud.CaptureCommand("kubectl", []string{"apply", "-f", filename})
+
+	var out []byte
+	var err2 error
+
+	if c.context.CurrentEnvironment.Kubernetes.Helm != nil {
+		out, err2 = c.deployHelmChart()
+	} else if contents, err := c.deploymentManifest(filename); err != nil {
+		err2 = err
+	} else {
+		out, err2 = c.gcloud.CaptureCommandWithInput("kubectl", c.namespaced([]string{"apply", "-f", "-"}), contents)
+	}
+
 	c.notifier.OnDeployed(string(out), err2)
 
 	os.Stderr.Write(out)
@@ -307,6 +717,30 @@ func (c *Client) deploy() error {
 	return nil
 }
 
+// deploymentManifest returns the rendered manifest together with decrypted secrets which
+// are kept in memory only and never written to the deployment file.
+func (c *Client) deploymentManifest(filename string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.context.CurrentEnvironment.Kubernetes.Kustomize != nil {
+		return c.context.FillSecrets(contents)
+	}
+
+	manifest, err := c.context.SecretsManifest()
+	if err != nil || manifest == "" {
+		return contents, err
+	}
+
+	if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+		contents = append(contents, '\n')
+	}
+
+	return append(contents, []byte("---\n"+manifest)...), nil
+}
+
 func (c *Client) deploymentFile() (string, error) {
 	env := c.context.Environment()
 	projectName := c.context.Config.Project.FullName()
@@ -315,23 +749,42 @@ func (c *Client) deploymentFile() (string, error) {
 
 func (c *Client) pushContainers() error {
 
-	client, err := containers.New(c.gcloud)
+	client, err := c.containersClient()
 	if err != nil {
 		return err
 	}
 
 	c.logger.Printf("Pushing containers")
 
-	for _, image := range c.context.Config.Images {
+	for _, image := range c.context.Images() {
+		tag := c.context.ContainerPath(image.Name)
+
+		if _, pushed := client.Result(tag); !pushed && !c.context.Version.IsSnapshot() && !c.config.ForceOverwrite {
+			pushed, err := client.VerifyReleaseTag(tag)
+			if err != nil {
+				c.notifier.OnImagePushed(image, "", err)
+				return err
+			}
+
+			if pushed {
+				c.logger.Printf("Release image %s was already pushed, skipping", tag)
+				c.notifier.OnImagePushed(image, fmt.Sprintf("%s was already pushed", tag), nil)
+				continue
+			}
+		}
+
 		c.notifier.OnImagePushing(image)
-		out, err := client.PushContainer(c.context.Container(image.Name))
+		out, err := client.PushContainer(tag)
 		c.notifier.OnImagePushed(image, string(out), err)
-		os.Stderr.Write(out)
 
 		if err != nil {
 			c.logger.Printf("Error pushing container: %s", err)
 			return err
 		}
+
+		if result, pushed := client.Result(tag); pushed {
+			c.recordImage(image, result)
+		}
 	}
 
 	return nil
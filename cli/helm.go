@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func (c *Client) helmValuesFile() string {
+	env := c.context.Environment()
+	return fmt.Sprintf("values-%s-%s.yml", c.context.Config.Project.FullName(), env.Name)
+}
+
+func (c *Client) helmArgs(command ...string) []string {
+	helm := c.context.CurrentEnvironment.Kubernetes.Helm
+
+	args := append(command, c.context.HelmRelease(), helm.Chart)
+
+	for _, values := range helm.Values {
+		args = append(args, "--values", values)
+	}
+
+	args = append(args, "--values", c.helmValuesFile())
+
+	return c.namespaced(args)
+}
+
+func (c *Client) renderHelmChart(filename string) error {
+	if err := c.context.WriteHelmValues(c.helmValuesFile()); err != nil {
+		return err
+	}
+
+	out, err := c.gcloud.CaptureCommand("helm", c.helmArgs("template"))
+	if err != nil {
+		os.Stderr.Write(out)
+		return err
+	}
+
+	return ioutil.WriteFile(filename, out, os.ModePerm)
+}
+
+func (c *Client) deployHelmChart() ([]byte, error) {
+	if out, err := c.applyConfigMaps(); err != nil {
+		return out, err
+	}
+
+	if out, err := c.applySecrets(); err != nil {
+		return out, err
+	}
+
+	out, err := c.gcloud.CaptureCommand("helm", c.helmArgs("upgrade", "--install"))
+	if err != nil {
+		return out, err
+	}
+
+	return out, os.Remove(c.helmValuesFile())
+}
+
+// applyConfigMaps applies generated ConfigMaps on their own as the chart does not contain them.
+func (c *Client) applyConfigMaps() ([]byte, error) {
+	manifest, err := c.context.ConfigMapsManifest()
+	if err != nil || manifest == "" {
+		return []byte{}, err
+	}
+
+	env := c.context.Environment()
+	filename := fmt.Sprintf("configmaps-%s-%s.yml", c.context.Config.Project.FullName(), env.Name)
+
+	if err := ioutil.WriteFile(filename, []byte(manifest), os.ModePerm); err != nil {
+		return []byte{}, err
+	}
+
+	defer os.Remove(filename)
+
+	return c.gcloud.CaptureCommand("kubectl", c.namespaced([]string{"apply", "-f", filename}))
+}
+
+// applySecrets applies decrypted secrets through stdin so they never touch the disk.
+func (c *Client) applySecrets() ([]byte, error) {
+	manifest, err := c.context.SecretsManifest()
+	if err != nil || manifest == "" {
+		return []byte{}, err
+	}
+
+	return c.gcloud.CaptureCommandWithInput("kubectl", c.namespaced([]string{"apply", "-f", "-"}), []byte(manifest))
+}
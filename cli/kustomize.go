@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func (c *Client) renderKustomization(filename string) error {
+	env := c.context.Environment()
+	dir := fmt.Sprintf(".kustomize-%s-%s", c.context.Config.Project.FullName(), env.Name)
+
+	defer os.RemoveAll(dir)
+
+	if err := c.context.WriteKustomization(dir); err != nil {
+		return err
+	}
+
+	out, err := c.gcloud.CaptureCommand("kubectl", []string{"kustomize", dir})
+	if err != nil {
+		os.Stderr.Write(out)
+		return err
+	}
+
+	return ioutil.WriteFile(filename, out, os.ModePerm)
+}
@@ -5,10 +5,14 @@ import (
 )
 
 type Args struct {
-	Steps         []string `arg:"positional,required"`
-	Environment   string   `arg:"--env" help:"Current environment"`
-	ProjectConfig string   `arg:"--config" help:"Project config yaml file"`
-	Update        bool     `arg:"--update" help:"Update gcloud components"`
+	Steps          []string `arg:"positional,required"`
+	Environment    string   `arg:"--env" help:"Current environment"`
+	ProjectConfig  string   `arg:"--config" help:"Project config yaml file"`
+	Update         bool     `arg:"--update" help:"Update gcloud components"`
+	Bump           string   `arg:"--bump" help:"Override version bump on release: major, minor or patch"`
+	Push           bool     `arg:"--push" help:"Push release tag to origin"`
+	ForceOverwrite bool     `arg:"--force-overwrite" help:"Allow overwriting already pushed release images"`
+	Report         string   `arg:"--report" help:"Run report file, empty disables the report"`
 }
 
 func Get() (*Args, error) {
@@ -18,6 +22,9 @@ func Get() (*Args, error) {
 	args.ProjectConfig = "project.yml"
 	args.Environment = "test"
 	args.Update = false
+	args.Push = false
+	args.ForceOverwrite = false
+	args.Report = "gcp-builder-report.json"
 
 	arg.MustParse(args)
 
@@ -0,0 +1,90 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/docker"
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
+	"io"
+)
+
+const defaultBuilder = "docker"
+
+// Builder builds an image from a build context. Remote builders push the image as part
+// of the build, images built by the Docker daemon are pushed in the push step.
+type Builder interface {
+	Name() string
+	PushesOnBuild() bool
+	Build(request BuildRequest, output io.Writer) (Result, error)
+}
+
+type BuildRequest struct {
+	Tag string
+	// Tags are additional tags pushed together with the image
+	Tags []string
+	// Context is the build context directory
+	Context string
+	// Dockerfile is the path of the rendered Dockerfile
+	Dockerfile string
+	Options    docker.BuildOptions
+	Secrets    []string
+	Cache      *CacheRequest
+}
+
+// Result is the pushed tag and its digest once an image went through build and push.
+type Result struct {
+	Tag     string
+	Digest  string
+	Pushed  bool
+	Builder string
+	// Tags are additional tags pushed together with the image
+	Tags        []string
+	Cache       *report.Cache
+	ContentHash string
+	// Digests of the image and all its additional tags
+	Digests map[string]string
+	// Reused is the image with the same content hash the tag points to instead of a new build
+	Reused string
+	// Staged is the staging tag a builder pushing on build pushed the image to, the push step
+	// points the tag and additional tags to it
+	Staged string
+	// Platforms of a multi-platform image, Digest is the digest of its manifest list
+	Platforms       []string
+	PlatformDigests map[string]string
+	// Revision is the commit the image was built from
+	Revision string
+}
+
+// builderName returns the builder of the image falling back to the environment one.
+func builderName(context *kubernetes.Context, image project.Image) string {
+	if image.Builder != "" {
+		return image.Builder
+	}
+
+	if context.CurrentEnvironment.Builder != "" {
+		return context.CurrentEnvironment.Builder
+	}
+
+	return defaultBuilder
+}
+
+func (c *Client) builder(context *kubernetes.Context, image project.Image) (Builder, error) {
+	switch name := builderName(context, image); name {
+	case "docker":
+		return &daemonBuilder{client: c}, nil
+	case "buildx", "buildkit":
+		return &buildxBuilder{gcloud: c.gcloud}, nil
+	case "kaniko":
+		return &kanikoBuilder{gcloud: c.gcloud}, nil
+	case "cloudbuild":
+		return &cloudBuilder{client: c, project: context.CurrentEnvironment.Cloud.Project}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("UnknownBuilder(%s): expected docker, buildx, kaniko or cloudbuild", name))
+	}
+}
+
+func unsupportedOption(builder, option string) error {
+	return errors.New(fmt.Sprintf("UnsupportedBuildOption(%s): %s is not supported", builder, option))
+}
@@ -0,0 +1,228 @@
+package containers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"github.com/wendigo/gcp-builder/docker"
+	"github.com/wendigo/gcp-builder/project"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestRequest() BuildRequest {
+	return BuildRequest{
+		Tag:        "eu.gcr.io/p/app:1.0.0",
+		Tags:       []string{"eu.gcr.io/p/app:latest"},
+		Context:    "build",
+		Dockerfile: "Dockerfile-test",
+		Options: docker.BuildOptions{
+			BuildArgs: map[string]string{"VERSION": "1.0.0", "HOME": "$HOME"},
+			Labels:    map[string]string{"team": "core"},
+			Target:    "production",
+			Platform:  "linux/arm64",
+		},
+	}
+}
+
+func TestBuilderSelection(t *testing.T) {
+	ctx := newLabelsContext(t)
+	ctx.Config.Images = []project.Image{{Name: "a"}, {Name: "b", Builder: "buildx"}, {Name: "c", Builder: "nope"}}
+	ctx.CurrentEnvironment.Builder = "kaniko"
+	ctx.CurrentEnvironment.Images = []project.Image{{Name: "b", Builder: "cloudbuild"}}
+
+	client := newTestClient()
+	images := ctx.Images()
+
+	for index, expected := range []string{"kaniko", "cloudbuild"} {
+		builder, err := client.builder(ctx, images[index])
+		if err != nil || builder.Name() != expected {
+			t.Errorf("%s: expected builder %s, got %v (%v)", images[index].Name, expected, builder, err)
+		}
+	}
+
+	if _, err := client.builder(ctx, images[2]); err == nil || !strings.Contains(err.Error(), "UnknownBuilder(nope)") {
+		t.Errorf("expected UnknownBuilder, got %v", err)
+	}
+
+	ctx.CurrentEnvironment.Builder = ""
+
+	if builder, _ := client.builder(ctx, images[0]); builder.Name() != "docker" || builder.PushesOnBuild() {
+		t.Errorf("expected docker builder by default, got %v", builder)
+	}
+}
+
+func TestBuildFlags(t *testing.T) {
+	options := newTestRequest().Options
+	options.NetworkMode = "host"
+	options.CacheFrom = []string{"eu.gcr.io/p/app:cache"}
+
+	expected := []string{
+		"--build-arg", "HOME=$HOME", "--build-arg", "VERSION=1.0.0",
+		"--label", "team=core",
+		"--target", "production",
+		"--network", "host",
+		"--secret", "id=npm,src=.npmrc",
+		"--cache-from", "eu.gcr.io/p/app:cache",
+		"--platform", "linux/arm64",
+	}
+
+	if flags := buildFlags(options, []string{"id=npm,src=.npmrc"}); !reflect.DeepEqual(flags, expected) {
+		t.Errorf("expected flags\n%v\ngot\n%v", expected, flags)
+	}
+}
+
+func TestBuildxArgs(t *testing.T) {
+	request := newTestRequest()
+	request.Secrets = []string{"id=npm,src=.npmrc"}
+	request.Cache = &CacheRequest{From: []string{"eu.gcr.io/p/app:cache-main"}, Export: CacheExportRegistry, Ref: "eu.gcr.io/p/app:cache-main"}
+
+	expected := []string{
+		"buildx", "build", "--file", "Dockerfile-test", "--tag", "eu.gcr.io/p/app:1.0.0", "--tag", "eu.gcr.io/p/app:latest",
+		"--cache-to", "type=registry,ref=eu.gcr.io/p/app:cache-main,mode=max",
+		"--build-arg", "HOME=$HOME", "--build-arg", "VERSION=1.0.0", "--label", "team=core", "--target", "production",
+		"--secret", "id=npm,src=.npmrc", "--cache-from", "eu.gcr.io/p/app:cache-main", "--platform", "linux/arm64",
+		"--push", "--metadata-file", "metadata.json", "build",
+	}
+
+	if args := (&buildxBuilder{}).args(request, "metadata.json"); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args\n%v\ngot\n%v", expected, args)
+	}
+}
+
+func TestKanikoArgs(t *testing.T) {
+	request := newTestRequest()
+	request.Cache = &CacheRequest{From: []string{"eu.gcr.io/p/app:cache-main"}, Repository: "eu.gcr.io/p/app/cache"}
+
+	context, _ := filepath.Abs("build")
+	dockerfile, _ := filepath.Abs("Dockerfile-test")
+
+	expected := []string{
+		"--context", "dir://" + context, "--dockerfile", dockerfile,
+		"--destination", "eu.gcr.io/p/app:1.0.0", "--digest-file", "digest", "--destination", "eu.gcr.io/p/app:latest",
+		"--cache=true", "--cache-repo", "eu.gcr.io/p/app/cache",
+		"--build-arg", "HOME=$HOME", "--build-arg", "VERSION=1.0.0", "--label", "team=core", "--target", "production",
+		"--custom-platform", "linux/arm64",
+	}
+
+	args, err := (&kanikoBuilder{}).args(request, "digest")
+	if err != nil || !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args\n%v\ngot\n%v (%v)", expected, args, err)
+	}
+
+	request.Options.NetworkMode = "host"
+
+	if _, err := (&kanikoBuilder{}).args(request, "digest"); err == nil || !strings.Contains(err.Error(), "UnsupportedBuildOption(kaniko)") {
+		t.Errorf("expected UnsupportedBuildOption, got %v", err)
+	}
+}
+
+func TestCloudBuildConfig(t *testing.T) {
+	request := newTestRequest()
+	request.Cache = &CacheRequest{From: []string{"eu.gcr.io/p/app:cache-main"}, Export: CacheExportInline, Ref: "eu.gcr.io/p/app:cache-main"}
+
+	config, err := (&cloudBuilder{}).config(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cloudBuildConfig{
+		Steps: []cloudBuildStep{
+			{Name: cloudBuildDockerImage, Entrypoint: "bash", Args: []string{"-c", "docker pull eu.gcr.io/p/app:cache-main || exit 0"}},
+			{Name: cloudBuildDockerImage, Args: []string{
+				"build", "--file", contextDockerfile, "--tag", "eu.gcr.io/p/app:1.0.0", "--tag", "eu.gcr.io/p/app:latest",
+				"--tag", "eu.gcr.io/p/app:cache-main",
+				"--build-arg", "HOME=$$HOME", "--build-arg", "VERSION=1.0.0", "--label", "team=core", "--target", "production",
+				"--cache-from", "eu.gcr.io/p/app:cache-main", "--platform", "linux/arm64", ".",
+			}},
+		},
+		Images: []string{"eu.gcr.io/p/app:1.0.0", "eu.gcr.io/p/app:latest", "eu.gcr.io/p/app:cache-main"},
+	}
+
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected config\n%+v\ngot\n%+v", expected, config)
+	}
+
+	request.Secrets = []string{"id=npm,src=.npmrc"}
+
+	if _, err := (&cloudBuilder{}).config(request); err == nil || !strings.Contains(err.Error(), "UnsupportedBuildOption(cloudbuild)") {
+		t.Errorf("expected UnsupportedBuildOption, got %v", err)
+	}
+}
+
+func TestStageContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-builder-containers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"build/.dockerignore":   "*.log\n.gcloudignore\n",
+		"build/.gcloudignore":   "main.go\n",
+		"build/main.go":         "package main",
+		"build/debug.log":       "ignored",
+		"build/Dockerfile-test": "FROM scratch",
+	}
+
+	for name, contents := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), os.ModePerm)
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	staging := filepath.Join(dir, "staging")
+	os.Mkdir(staging, os.ModePerm)
+
+	request := BuildRequest{Context: filepath.Join(dir, "build"), Dockerfile: filepath.Join(dir, "build", "Dockerfile-test")}
+
+	source, err := stageContext(request, staging)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := tar.NewReader(compressed)
+	names := make([]string, 0)
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, header.Name)
+	}
+
+	expected := []string{".dockerignore", "Dockerfile-test", "main.go", contextDockerfile}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected archive %v, got %v", expected, names)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "build", contextDockerfile)); !os.IsNotExist(err) {
+		t.Errorf("expected build context to be left untouched, got %v", err)
+	}
+}
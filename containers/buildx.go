@@ -0,0 +1,88 @@
+package containers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/gcloud"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// buildxBuilder builds and pushes with docker buildx, registry credentials are read
+// from the docker config.
+type buildxBuilder struct {
+	gcloud *gcloud.Client
+}
+
+type buildxMetadata struct {
+	Digest string `json:"containerimage.digest"`
+}
+
+func (b *buildxBuilder) Name() string {
+	return "buildx"
+}
+
+func (b *buildxBuilder) PushesOnBuild() bool {
+	return true
+}
+
+func (b *buildxBuilder) Build(request BuildRequest, output io.Writer) (Result, error) {
+	result := Result{Tag: request.Tag, Builder: b.Name()}
+
+	metadata, err := ioutil.TempFile("", "gcp-builder-buildx")
+	if err != nil {
+		return result, err
+	}
+
+	metadata.Close()
+
+	defer os.Remove(metadata.Name())
+
+	out, err := b.gcloud.CaptureCommand("docker", b.args(request, metadata.Name()))
+	output.Write(out)
+
+	if err != nil {
+		return result, err
+	}
+
+	contents, err := ioutil.ReadFile(metadata.Name())
+	if err != nil {
+		return result, err
+	}
+
+	parsed := buildxMetadata{}
+
+	if err := json.Unmarshal(contents, &parsed); err != nil || parsed.Digest == "" {
+		return result, errors.New(fmt.Sprintf("MissingDigest(%s): buildx did not report the pushed digest", request.Tag))
+	}
+
+	result.Digest = parsed.Digest
+	result.Pushed = true
+
+	return result, nil
+}
+
+func (b *buildxBuilder) args(request BuildRequest, metadataFile string) []string {
+	args := []string{"buildx", "build", "--file", request.Dockerfile, "--tag", request.Tag}
+
+	for _, tag := range request.Tags {
+		args = append(args, "--tag", tag)
+	}
+
+	if request.Cache != nil {
+		request.Options.CacheFrom = request.Cache.From
+
+		switch request.Cache.Export {
+		case CacheExportInline:
+			args = append(args, "--tag", request.Cache.Ref, "--cache-to", "type=inline")
+		case CacheExportRegistry:
+			args = append(args, "--cache-to", fmt.Sprintf("type=registry,ref=%s,mode=max", request.Cache.Ref))
+		}
+	}
+
+	args = append(args, buildFlags(request.Options, request.Secrets)...)
+
+	return append(args, "--push", "--metadata-file", metadataFile, request.Context)
+}
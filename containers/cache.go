@@ -0,0 +1,148 @@
+package containers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
+	"regexp"
+	"strings"
+)
+
+const defaultCacheBranch = "master"
+const cacheTagPrefix = "cache-"
+
+const (
+	CacheExportInline   = "inline"
+	CacheExportRegistry = "registry"
+)
+
+var buildkitStep = regexp.MustCompile(`^#(\d+) \[(?:\S+ )?\d+/\d+\] `)
+var buildkitCached = regexp.MustCompile(`^#(\d+) CACHED`)
+
+// CacheRequest holds resolved cache references of an image build.
+type CacheRequest struct {
+	// From are cache sources, builders skip the ones which do not exist
+	From   []string
+	Export string
+	// Ref is the cache of the current branch where the cache is exported to
+	Ref string
+	// Repository is used by builders keeping cache layers in a separate repository
+	Repository string
+}
+
+// cacheRequest resolves "branch", "default" and tag cache sources to image references.
+// The cache is exported only when the current branch is known.
+func cacheRequest(context *kubernetes.Context, image project.Image) (*CacheRequest, error) {
+	if image.Cache == nil {
+		return nil, nil
+	}
+
+	switch image.Cache.Export {
+	case "", CacheExportInline, CacheExportRegistry:
+	default:
+		return nil, errors.New(fmt.Sprintf("InvalidCacheExport(%s): expected inline or registry", image.Cache.Export))
+	}
+
+	defaultBranch := image.Cache.DefaultBranch
+	if defaultBranch == "" {
+		defaultBranch = defaultCacheBranch
+	}
+
+	branch := context.Build.Branch
+	request := &CacheRequest{
+		From:       make([]string, 0),
+		Export:     image.Cache.Export,
+		Repository: fmt.Sprintf("%s/cache", repositoryOf(context.ContainerPath(image.Name))),
+	}
+
+	if branch != "" {
+		request.Ref = context.ContainerVersion(image.Name, cacheTagPrefix+branch)
+	}
+
+	for _, from := range image.Cache.From {
+		var ref string
+
+		switch from {
+		case "branch":
+			ref = request.Ref
+		case "default":
+			ref = context.ContainerVersion(image.Name, cacheTagPrefix+defaultBranch)
+		default:
+			ref = context.ContainerVersion(image.Name, from)
+		}
+
+		if ref != "" && !contains(request.From, ref) {
+			request.From = append(request.From, ref)
+		}
+	}
+
+	if request.Ref == "" {
+		request.Export = ""
+	}
+
+	return request, nil
+}
+
+// cacheStatistics counts cached and all build steps in the output of the classic builder,
+// BuildKit and kaniko.
+func cacheStatistics(output []byte) (int, int) {
+	hits, steps := 0, 0
+	buildkitSteps := make(map[string]bool)
+	buildkitHits := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "Step ") && strings.Contains(line, "/"):
+			steps++
+		case strings.Contains(line, "---> Using cache"):
+			hits++
+		case strings.Contains(line, "Using caching version of cmd"):
+			hits++
+			steps++
+		case strings.Contains(line, "No cached layer found for cmd"):
+			steps++
+		case buildkitStep.MatchString(line):
+			buildkitSteps[buildkitStep.FindStringSubmatch(line)[1]] = true
+		case buildkitCached.MatchString(line):
+			buildkitHits[buildkitCached.FindStringSubmatch(line)[1]] = true
+		}
+	}
+
+	for id := range buildkitHits {
+		if buildkitSteps[id] {
+			hits++
+		}
+	}
+
+	return hits, steps + len(buildkitSteps)
+}
+
+func cacheReport(request *CacheRequest, output []byte) *report.Cache {
+	hits, steps := cacheStatistics(output)
+
+	return &report.Cache{
+		From:   request.From,
+		Export: request.Export,
+		Hits:   hits,
+		Steps:  steps,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
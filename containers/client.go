@@ -1,109 +1,554 @@
 package containers
 
 import (
-	"encoding/json"
+	"bytes"
 	"errors"
 	"fmt"
+	"github.com/wendigo/gcp-builder/docker"
 	"github.com/wendigo/gcp-builder/gcloud"
 	"github.com/wendigo/gcp-builder/kubernetes"
 	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/registry"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 	"strings"
 )
 
 type Client struct {
-	gcloud *gcloud.Client
-	logger *log.Logger
+	gcloud   *gcloud.Client
+	docker   *docker.Client
+	registry *registry.Client
+	logger   *log.Logger
+	results  map[string]Result
+	// registries with explicit credentials
+	registries []project.Registry
 }
 
-func New(gcloud *gcloud.Client) (*Client, error) {
-	return &Client{
+func New(gcloud *gcloud.Client, registries []project.Registry) (*Client, error) {
+	client, err := docker.NewFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	containers := &Client{
 		gcloud: gcloud,
+		docker: client,
 		logger: log.New(
 			os.Stdout, "[containers] ", log.Lmicroseconds,
 		),
-	}, nil
+		results:    make(map[string]Result),
+		registries: registries,
+	}
+
+	containers.registry = registry.New(containers.registryCredentials)
+
+	return containers, nil
 }
 
-func (c *Client) BuildContainer(context *kubernetes.Context, image project.Image) ([]byte, error) {
+const stagingTagSuffix = "staging"
+
+// BuildContainer builds the image with the builder configured for the image or environment
+// streaming progress to stderr. Build output is returned also when the build failed.
+// With stage set builders pushing on build push the image to a staging tag only, so it can be
+// tested and scanned before the push step publishes it.
+func (c *Client) BuildContainer(context *kubernetes.Context, image project.Image, stage bool) (Result, []byte, error) {
 	tag := context.ContainerPath(image.Name)
 
+	builder, err := c.builder(context, image)
+	if err != nil {
+		return Result{}, []byte{}, err
+	}
+
+	cache, err := cacheRequest(context, image)
+	if err != nil {
+		return Result{}, []byte{}, err
+	}
+
 	if image.Dockerfile == "" {
-		image.Dockerfile = "Dockerfile"
+		image.Dockerfile = project.DefaultDockerfile
 	}
 
 	dockerfile := fmt.Sprintf("%s-%s", image.Dockerfile, context.CurrentEnvironment.Name)
 
-	c.logger.Printf("Building container %s [%s] from build context %s", image.Name, tag, image.Build)
+	c.logger.Printf("Building container %s [%s] from build context %s with %s", image.Name, tag, image.Build, builder.Name())
 
 	if err := context.InterpolateConfig(image.Dockerfile, dockerfile); err != nil {
-		return []byte{}, err
-	}
-
-	args := []string{
-		"docker",
-		fmt.Sprintf("--docker-host=%s", os.Getenv("DOCKER_HOST")),
-		"--",
-		"build",
-		"--file",
-		dockerfile,
-		"-t",
-		tag,
-		image.Build,
+		return Result{}, []byte{}, err
 	}
 
 	defer func() {
 		os.Remove(dockerfile)
 	}()
 
-	return c.gcloud.CaptureCommand("gcloud", args)
+	options, err := buildOptions(context, image)
+	if err != nil {
+		return Result{}, []byte{}, err
+	}
+
+	tags, err := policyTags(context, image)
+	if err != nil {
+		return Result{}, []byte{}, err
+	}
+
+	hash := ""
+
+	if image.Reuse != nil && *image.Reuse {
+		contents, err := ioutil.ReadFile(dockerfile)
+		if err != nil {
+			return Result{}, []byte{}, err
+		}
+
+		if hash, err = contentHash(image.Build, contents, options); err != nil {
+			return Result{}, []byte{}, err
+		}
+
+		contentTag := context.ContainerVersion(image.Name, contentTagPrefix+hash)
+
+		if reused, err := c.reuse(tag, contentTag, hash, builder); err != nil || reused != nil {
+			if reused != nil {
+				reused.Tags = tags
+				reused.Revision = context.Commit.Hash
+				c.results[tag] = *reused
+				return *reused, []byte(fmt.Sprintf("%s is unchanged, reusing %s", image.Name, contentTag)), nil
+			}
+
+			return Result{}, []byte{}, err
+		}
+
+		options.Labels[contentHashLabel] = hash
+		tags = append(tags, contentTag)
+	}
+
+	output := &bytes.Buffer{}
+	staged := ""
+
+	if stage && builder.PushesOnBuild() {
+		staged = context.ContainerVersion(image.Name, fmt.Sprintf("%s-%s", context.Version.Tag(), stagingTagSuffix))
+		c.logger.Printf("Container %s is pushed to %s until it is promoted in the push step", image.Name, staged)
+	}
+
+	request := BuildRequest{
+		Tag:        tag,
+		Tags:       tags,
+		Context:    image.Build,
+		Dockerfile: dockerfile,
+		Options:    options,
+		Secrets:    image.Secrets,
+		Cache:      cache,
+	}
+
+	if staged != "" {
+		request.Tag = staged
+		request.Tags = nil
+	}
+
+	var result Result
+
+	if len(image.Platforms) > 0 && !buildsPlatforms(builder) {
+		result, err = c.buildPlatforms(builder, request, image.Platforms, io.MultiWriter(os.Stderr, output))
+	} else {
+		result, err = builder.Build(request, io.MultiWriter(os.Stderr, output))
+	}
+
+	if err != nil {
+		return result, output.Bytes(), err
+	}
+
+	result.ContentHash = hash
+	result.Revision = context.Commit.Hash
+
+	if staged != "" {
+		result.Tag = tag
+		result.Tags = tags
+		result.Staged = staged
+		result.Pushed = false
+	}
+
+	if len(image.Platforms) > 0 && buildsPlatforms(builder) {
+		manifest, err := c.registry.Manifest(request.Tag)
+		if err != nil {
+			return result, output.Bytes(), err
+		}
+
+		result.Platforms = image.Platforms
+		result.PlatformDigests = manifest.Platforms()
+	}
+
+	if result.Pushed {
+		result.Digests = make(map[string]string)
+
+		for _, reference := range append([]string{tag}, result.Tags...) {
+			result.Digests[reference] = result.Digest
+		}
+	}
+
+	if cache != nil {
+		result.Cache = cacheReport(cache, output.Bytes())
+		c.logger.Printf("Container %s reused %d of %d build steps from cache", image.Name, result.Cache.Hits, result.Cache.Steps)
+	}
+
+	c.results[tag] = result
+
+	return result, output.Bytes(), nil
+}
+
+// reuse looks up an image built from the same content. The tag is pointed to it in the push step.
+func (c *Client) reuse(tag, contentTag, hash string, builder Builder) (*Result, error) {
+	digest, labels, err := c.registry.Labels(contentTag)
+	if registry.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil || labels[contentHashLabel] != hash {
+		return nil, err
+	}
+
+	c.logger.Printf("Container %s is unchanged, reusing %s", tag, contentTag)
+
+	return &Result{
+		Tag:         tag,
+		Digest:      digest,
+		Builder:     builder.Name(),
+		ContentHash: hash,
+		Reused:      contentTag,
+	}, nil
+}
+
+// PushesOnBuild tells whether the image is pushed by its builder already in the build step.
+func (c *Client) PushesOnBuild(context *kubernetes.Context, image project.Image) (bool, error) {
+	builder, err := c.builder(context, image)
+	if err != nil {
+		return false, err
+	}
+
+	return builder.PushesOnBuild(), nil
+}
+
+// Result returns the pushed tag and its digest of an image built or pushed in this run.
+func (c *Client) Result(tag string) (Result, bool) {
+	result, exists := c.results[tag]
+	return result, exists && result.Pushed
+}
+
+// buildOptions renders build args and labels as templates, labels defined for the image
+// take precedence over default ones.
+func buildOptions(context *kubernetes.Context, image project.Image) (docker.BuildOptions, error) {
+	buildArgs, err := renderValues(context, image.BuildArgs)
+	if err != nil {
+		return docker.BuildOptions{}, err
+	}
+
+	labels, err := renderValues(context, image.Labels)
+	if err != nil {
+		return docker.BuildOptions{}, err
+	}
+
+	for key, value := range defaultLabels(context, image) {
+		if _, exists := labels[key]; !exists {
+			labels[key] = value
+		}
+	}
+
+	platform := image.Platform
+
+	if len(image.Platforms) > 0 {
+		if image.Platform != "" {
+			return docker.BuildOptions{}, errors.New(fmt.Sprintf("ConflictingPlatforms(%s): set either platform or platforms", image.Name))
+		}
+
+		platform = strings.Join(image.Platforms, ",")
+	}
+
+	return docker.BuildOptions{
+		BuildArgs:   buildArgs,
+		Labels:      labels,
+		Target:      image.Target,
+		NetworkMode: image.Network,
+		Platform:    platform,
+	}, nil
+}
+
+// buildFlags returns docker build flags for build args, labels, target, network, secrets and platform.
+func buildFlags(options docker.BuildOptions, secrets []string) []string {
+	flags := make([]string, 0)
+
+	for _, flag := range []struct {
+		name   string
+		values map[string]string
+	}{
+		{"--build-arg", options.BuildArgs},
+		{"--label", options.Labels},
+	} {
+		keys := make([]string, 0)
+
+		for key := range flag.values {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			flags = append(flags, flag.name, fmt.Sprintf("%s=%s", key, flag.values[key]))
+		}
+	}
+
+	if options.Target != "" {
+		flags = append(flags, "--target", options.Target)
+	}
+
+	if options.NetworkMode != "" {
+		flags = append(flags, "--network", options.NetworkMode)
+	}
+
+	for _, secret := range secrets {
+		flags = append(flags, "--secret", secret)
+	}
+
+	for _, cacheFrom := range options.CacheFrom {
+		flags = append(flags, "--cache-from", cacheFrom)
+	}
+
+	if options.Platform != "" {
+		flags = append(flags, "--platform", options.Platform)
+	}
+
+	return flags
+}
+
+func renderValues(context *kubernetes.Context, values map[string]string) (map[string]string, error) {
+	rendered := make(map[string]string)
+
+	for key, value := range values {
+		output, err := context.Render(value)
+		if err != nil {
+			return rendered, err
+		}
+
+		rendered[key] = output
+	}
+
+	return rendered, nil
 }
 
+// PushContainer pushes an image built by the Docker daemon together with its additional tags,
+// images pushed by their builder are skipped.
 func (c *Client) PushContainer(tag string) ([]byte, error) {
-	c.logger.Printf("Pushing container %s", tag)
+	result, exists := c.results[tag]
 
-	args := []string{
-		"docker",
-		fmt.Sprintf("--docker-host=%s", os.Getenv("DOCKER_HOST")),
-		"--",
-		"push",
-		tag,
+	if exists && result.Pushed {
+		c.logger.Printf("Container %s was pushed by %s", tag, result.Builder)
+		return []byte(fmt.Sprintf("%s was pushed by %s with digest %s", tag, result.Builder, result.Digest)), nil
 	}
 
-	return c.gcloud.CaptureCommand("gcloud", args)
+	if !exists {
+		result = Result{Tag: tag, Builder: defaultBuilder}
+	}
+
+	output := &bytes.Buffer{}
+	result.Digests = make(map[string]string)
+
+	source := result.Reused
+	if result.Staged != "" {
+		source = result.Staged
+	}
+
+	if len(result.Platforms) > 0 && source == "" {
+		if err := c.pushPlatforms(&result, output); err != nil {
+			return output.Bytes(), err
+		}
+
+		for _, reference := range append([]string{tag}, result.Tags...) {
+			result.Digests[reference] = result.Digest
+		}
+
+		result.Pushed = true
+		c.results[tag] = result
+
+		return output.Bytes(), nil
+	}
+
+	for _, reference := range append([]string{tag}, result.Tags...) {
+		var digest string
+		var err error
+
+		if source != "" {
+			digest, err = c.retag(source, reference)
+		} else {
+			c.logger.Printf("Pushing container %s", reference)
+			digest, err = c.push(reference, output)
+		}
+
+		if err != nil {
+			return output.Bytes(), err
+		}
+
+		if source != "" {
+			fmt.Fprintf(output, "%s points to %s with digest %s\n", reference, source, digest)
+		}
+
+		result.Digests[reference] = digest
+	}
+
+	if result.Staged != "" {
+		c.removeStagingTag(result, output)
+		result.Staged = ""
+	}
+
+	result.Digest = result.Digests[tag]
+	result.Pushed = true
+	c.results[tag] = result
+
+	return output.Bytes(), nil
 }
 
-func (c *Client) ContainerSha256(context *kubernetes.Context, image project.Image) (string, error) {
-	tag := context.ContainerPath(image.Name)
+// RemoveStagingTags deletes staging tags of images which were not promoted, e.g. when their
+// tests failed.
+func (c *Client) RemoveStagingTags() {
+	for tag, result := range c.results {
+		if result.Staged != "" {
+			c.removeStagingTag(result, os.Stderr)
+			result.Staged = ""
+			c.results[tag] = result
+		}
+	}
+}
+
+// removeStagingTag deletes the staging tag and tags of its platform images. Failures are
+// only reported as the image itself was already handled.
+func (c *Client) removeStagingTag(result Result, output io.Writer) {
+	references := []string{result.Staged}
+
+	for _, platform := range result.Platforms {
+		references = append(references, platformTag(result.Staged, platform))
+	}
 
-	args := []string{
-		"-H",
-		os.Getenv("DOCKER_HOST"),
-		"inspect",
-		tag,
+	for _, reference := range references {
+		if err := c.registry.DeleteTag(reference); err != nil && !registry.IsNotFound(err) {
+			fmt.Fprintf(output, "Could not remove staging tag %s: %s\n", reference, err)
+		} else if err == nil {
+			c.logger.Printf("Removed staging tag %s", reference)
+		}
 	}
+}
 
-	output, err := c.gcloud.CaptureCommand("docker", args)
+func (c *Client) push(reference string, output io.Writer) (string, error) {
+	auth, _, err := c.credentials(reference)
 	if err != nil {
 		return "", err
 	}
 
-	inspect := make(inspect, 0)
+	return c.docker.Push(reference, auth, io.MultiWriter(os.Stderr, output))
+}
 
-	if err := json.Unmarshal(output, &inspect); err != nil {
-		return "", err
+// ReleasePushed checks whether a release tag exists in the registry. It is used for builders
+// pushing on build which must not overwrite release images, the existing image is reused.
+func (c *Client) ReleasePushed(tag string) (bool, error) {
+	digest, err := c.registry.Digest(tag)
+	if registry.IsNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	c.results[tag] = Result{Tag: tag, Digest: digest, Pushed: true, Builder: "registry"}
+
+	return true, nil
+}
+
+// VerifyReleaseTag checks whether a release tag was already pushed. True is returned when the
+// registry holds the very same image so the push can be skipped, an error when it holds a
+// different one as release images must never be overwritten.
+func (c *Client) VerifyReleaseTag(tag string) (bool, error) {
+	c.logger.Printf("Checking whether %s was already pushed", tag)
+
+	if result, exists := c.results[tag]; exists && len(result.Platforms) > 0 && result.Reused == "" && result.Staged == "" {
+		if err := c.verifyPlatformTags(tag, result.Platforms); err != nil {
+			return false, err
+		}
 	}
 
-	if len(inspect) == 0 {
-		return "", errors.New(fmt.Sprintf("Could not find image with tag %s to inspect", tag))
+	remote, err := c.remoteManifest(tag)
+	if err != nil {
+		return false, err
 	}
 
-	if len(inspect[0].RepoDigests) == 0 {
-		return "", errors.New(fmt.Sprintf("Image with tag %s was not pushed so remote digest cannot be determined", tag))
+	if remote == nil {
+		return false, nil
 	}
 
-	prefix := strings.TrimSuffix(tag, fmt.Sprintf(":%s", context.Version))
+	if result, exists := c.results[tag]; exists && (result.Reused != "" || result.Staged != "") {
+		if remote.Digest == result.Digest {
+			return true, nil
+		}
+
+		return c.pushedRevision(tag, tag, remote)
+	}
+
+	if result, exists := c.results[tag]; exists && len(result.Platforms) > 0 {
+		if same, err := c.samePlatforms(tag, remote, result.Platforms); err != nil || same {
+			return same, err
+		}
+
+		return c.pushedRevision(tag, tag, remote)
+	}
+
+	local, err := c.docker.Inspect(tag)
+	if err != nil {
+		return false, err
+	}
+
+	if configId := remote.ConfigDigest(); configId != "" && configId == local.Id {
+		return true, nil
+	}
+
+	for _, digest := range local.RepoDigests {
+		if remote.Digest != "" && strings.HasSuffix(digest, fmt.Sprintf("@%s", remote.Digest)) {
+			return true, nil
+		}
+	}
+
+	return c.pushedRevision(tag, tag, remote)
+}
+
+// pushedRevision accepts a release reference holding a different image built from the same
+// commit, as labels of a rerun differ. The pushed image is kept, anything else must not be
+// overwritten.
+func (c *Client) pushedRevision(tag string, reference string, remote *registry.Manifest) (bool, error) {
+	if revision := c.results[tag].Revision; revision != "" {
+		_, labels, err := c.registry.Labels(reference)
+		if err != nil {
+			return false, err
+		}
+
+		if labels[ociLabelPrefix+".revision"] == revision {
+			c.logger.Printf("%s was already pushed from commit %s, keeping it", reference, revision)
+
+			if reference == tag {
+				c.results[tag] = Result{Tag: tag, Digest: remote.Digest, Pushed: true, Builder: "registry"}
+			}
+
+			return true, nil
+		}
+	}
+
+	return false, errors.New(fmt.Sprintf(
+		"ReleaseImageAlreadyPushed(%s): registry holds a different image (%s), use --force-overwrite to replace it",
+		reference,
+		remote.Digest,
+	))
+}
+
+func (c *Client) ContainerSha256(context *kubernetes.Context, image project.Image) (string, error) {
+	tag := context.ContainerPath(image.Name)
+
+	if result, pushed := c.Result(tag); pushed && result.Digest != "" {
+		return result.Digest, nil
+	}
 
-	return strings.TrimPrefix(inspect[0].RepoDigests[0], fmt.Sprintf("%s@", prefix)), nil
+	return c.remoteDigest(tag)
 }
@@ -0,0 +1,214 @@
+package containers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/wendigo/gcp-builder/registry"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRegistry serves manifests and image configs of the team/app repository.
+type testRegistry struct {
+	*httptest.Server
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newTestRegistry() *testRegistry {
+	registry := &testRegistry{manifests: make(map[string][]byte), blobs: make(map[string][]byte)}
+	registry.Server = httptest.NewServer(http.HandlerFunc(registry.serve))
+
+	return registry
+}
+
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+// image stores an image with the given revision label under tag and returns its digest.
+func (r *testRegistry) image(tag string, revision string) string {
+	config := []byte(fmt.Sprintf(`{"config":{"Labels":{"org.opencontainers.image.revision":"%s"}}}`, revision))
+	r.blobs[digestOf(config)] = config
+
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"digest":"%s"}}`, registry.MediaTypeDockerManifest, digestOf(config)))
+	r.manifests[tag] = manifest
+
+	return digestOf(manifest)
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, request *http.Request) {
+	if strings.HasPrefix(request.URL.Path, "/v2/team/app/blobs/") {
+		blob, exists := r.blobs[strings.TrimPrefix(request.URL.Path, "/v2/team/app/blobs/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write(blob)
+		return
+	}
+
+	tag := strings.TrimPrefix(request.URL.Path, "/v2/team/app/manifests/")
+
+	if _, exists := r.manifests[tag]; request.Method == "DELETE" && exists {
+		delete(r.manifests, tag)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if request.Method == "PUT" {
+		r.manifests[tag], _ = ioutil.ReadAll(request.Body)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	manifest, exists := r.manifests[tag]
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", registry.MediaTypeDockerManifest)
+	w.Header().Set("Docker-Content-Digest", digestOf(manifest))
+	w.Write(manifest)
+}
+
+func digestOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func newTestClient() *Client {
+	return &Client{
+		registry: registry.New(func(string) (registry.Credentials, error) { return registry.Credentials{}, nil }),
+		logger:   log.New(ioutil.Discard, "", 0),
+		results:  make(map[string]Result),
+	}
+}
+
+func TestVerifyReleaseTagOfRerun(t *testing.T) {
+	server := newTestRegistry()
+	defer server.Close()
+
+	tag := server.host() + "/team/app:1.0.0"
+	staged := server.host() + "/team/app:1.0.0-staging"
+	pushed := server.image("1.0.0", "0123456")
+
+	tests := []struct {
+		revision string
+		same     bool
+	}{
+		{"0123456", true},
+		{"fedcba9", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		client := newTestClient()
+		client.results[tag] = Result{Tag: tag, Staged: staged, Digest: server.image("1.0.0-staging", test.revision), Revision: test.revision}
+
+		same, err := client.VerifyReleaseTag(tag)
+
+		if test.same && (err != nil || !same) {
+			t.Errorf("%s: expected image of the same commit to be kept, got %t (%v)", test.revision, same, err)
+		}
+
+		if !test.same && (err == nil || !strings.Contains(err.Error(), "ReleaseImageAlreadyPushed")) {
+			t.Errorf("%s: expected ReleaseImageAlreadyPushed, got %t (%v)", test.revision, same, err)
+		}
+
+		if result, _ := client.Result(tag); test.same && result.Digest != pushed {
+			t.Errorf("%s: expected pushed digest %s, got %s", test.revision, pushed, result.Digest)
+		}
+	}
+}
+
+func TestVerifyReleaseTagOfSameImage(t *testing.T) {
+	server := newTestRegistry()
+	defer server.Close()
+
+	tag := server.host() + "/team/app:1.0.0"
+	client := newTestClient()
+	client.results[tag] = Result{Tag: tag, Staged: server.host() + "/team/app:1.0.0-staging", Digest: server.image("1.0.0", "0123456")}
+
+	if same, err := client.VerifyReleaseTag(tag); err != nil || !same {
+		t.Errorf("expected the same image to be skipped, got %t (%v)", same, err)
+	}
+
+	if same, err := client.VerifyReleaseTag(server.host() + "/team/app:2.0.0"); err != nil || same {
+		t.Errorf("expected a new release to be pushed, got %t (%v)", same, err)
+	}
+}
+
+func TestPushStagedImage(t *testing.T) {
+	server := newTestRegistry()
+	defer server.Close()
+
+	tag := server.host() + "/team/app:1.0.0"
+	latest := server.host() + "/team/app:latest"
+	staged := server.host() + "/team/app:1.0.0-staging"
+	digest := server.image("1.0.0-staging", "0123456")
+
+	client := newTestClient()
+	client.results[tag] = Result{Tag: tag, Tags: []string{latest}, Staged: staged, Digest: digest, Builder: "kaniko"}
+
+	if _, pushed := client.Result(tag); pushed {
+		t.Fatal("expected staged image not to be reported as pushed")
+	}
+
+	if _, err := client.PushContainer(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	result, pushed := client.Result(tag)
+
+	if !pushed || result.Digest != digest || result.Digests[latest] != digest || result.Staged != "" {
+		t.Errorf("expected tags pointing to the staged image, got %+v", result)
+	}
+
+	for _, name := range []string{"1.0.0", "latest"} {
+		if _, exists := server.manifests[name]; !exists {
+			t.Errorf("expected tag %s to be pushed", name)
+		}
+	}
+
+	if _, exists := server.manifests["1.0.0-staging"]; exists {
+		t.Error("expected staging tag to be removed after promotion")
+	}
+}
+
+func TestRemoveStagingTags(t *testing.T) {
+	server := newTestRegistry()
+	defer server.Close()
+
+	tag := server.host() + "/team/app:1.0.0"
+	staged := server.host() + "/team/app:1.0.0-staging"
+
+	client := newTestClient()
+	client.results[tag] = Result{
+		Tag:       tag,
+		Staged:    staged,
+		Digest:    server.image("1.0.0-staging", "0123456"),
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+	}
+
+	server.image("1.0.0-staging-linux-amd64", "0123456")
+	server.image("1.0.0-staging-linux-arm64", "0123456")
+	server.image("1.0.0", "fedcba9")
+
+	client.RemoveStagingTags()
+
+	if len(server.manifests) != 1 || server.manifests["1.0.0"] == nil {
+		t.Errorf("expected only staging tags to be removed, got %v", server.manifests)
+	}
+
+	if result, _ := client.Result(tag); result.Staged != "" {
+		t.Errorf("expected staging tag to be forgotten, got %s", result.Staged)
+	}
+}
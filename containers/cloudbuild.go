@@ -0,0 +1,186 @@
+package containers
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/docker"
+	"github.com/wendigo/gcp-builder/registry"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const cloudBuildDockerImage = "gcr.io/cloud-builders/docker"
+
+// cloudBuilder submits the build context to Google Cloud Build which builds and pushes the image.
+type cloudBuilder struct {
+	client  *Client
+	project string
+}
+
+type cloudBuildConfig struct {
+	Steps  []cloudBuildStep `yaml:"steps"`
+	Images []string         `yaml:"images"`
+}
+
+type cloudBuildStep struct {
+	Name       string   `yaml:"name"`
+	Entrypoint string   `yaml:"entrypoint,omitempty"`
+	Args       []string `yaml:"args"`
+}
+
+func (b *cloudBuilder) Name() string {
+	return "cloudbuild"
+}
+
+func (b *cloudBuilder) PushesOnBuild() bool {
+	return true
+}
+
+func (b *cloudBuilder) Build(request BuildRequest, output io.Writer) (Result, error) {
+	result := Result{Tag: request.Tag, Builder: b.Name()}
+
+	config, err := b.config(request)
+	if err != nil {
+		return result, err
+	}
+
+	dir, err := ioutil.TempDir("", "gcp-builder-cloudbuild")
+	if err != nil {
+		return result, err
+	}
+
+	defer os.RemoveAll(dir)
+
+	source, err := stageContext(request, dir)
+	if err != nil {
+		return result, err
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return result, err
+	}
+
+	configFile := filepath.Join(dir, "cloudbuild.yaml")
+
+	if err := ioutil.WriteFile(configFile, out, 0644); err != nil {
+		return result, err
+	}
+
+	submit := []string{"builds", "submit", source, "--config", configFile}
+
+	if b.project != "" {
+		submit = append(submit, "--project", b.project)
+	}
+
+	out, err = b.client.gcloud.CaptureCommand("gcloud", submit)
+	output.Write(out)
+
+	if err != nil {
+		return result, err
+	}
+
+	digest, err := b.client.registry.Digest(request.Tag)
+	if registry.IsNotFound(err) {
+		return result, errors.New(fmt.Sprintf("MissingDigest(%s): image built by Cloud Build was not found in the registry", request.Tag))
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Digest = digest
+	result.Pushed = true
+
+	return result, nil
+}
+
+// config returns the build configuration, the rendered Dockerfile is a part of the staged context.
+func (b *cloudBuilder) config(request BuildRequest) (cloudBuildConfig, error) {
+	if len(request.Secrets) > 0 {
+		return cloudBuildConfig{}, unsupportedOption(b.Name(), "secrets")
+	}
+
+	args := []string{"build", "--file", contextDockerfile, "--tag", request.Tag}
+	steps := make([]cloudBuildStep, 0)
+	images := append([]string{request.Tag}, request.Tags...)
+
+	for _, tag := range request.Tags {
+		args = append(args, "--tag", tag)
+	}
+
+	if request.Cache != nil {
+		if request.Cache.Export == CacheExportRegistry {
+			return cloudBuildConfig{}, unsupportedOption(b.Name(), "registry cache export")
+		}
+
+		request.Options.CacheFrom = request.Cache.From
+
+		// missing cache images must not fail the build
+		for _, ref := range request.Cache.From {
+			steps = append(steps, cloudBuildStep{
+				Name:       cloudBuildDockerImage,
+				Entrypoint: "bash",
+				Args:       []string{"-c", fmt.Sprintf("docker pull %s || exit 0", ref)},
+			})
+		}
+
+		if request.Cache.Export == CacheExportInline {
+			args = append(args, "--tag", request.Cache.Ref)
+			images = append(images, request.Cache.Ref)
+		}
+	}
+
+	for _, flag := range buildFlags(request.Options, nil) {
+		// $ starts a Cloud Build substitution
+		args = append(args, strings.Replace(flag, "$", "$$", -1))
+	}
+
+	return cloudBuildConfig{
+		Steps:  append(steps, cloudBuildStep{Name: cloudBuildDockerImage, Args: append(args, ".")}),
+		Images: images,
+	}, nil
+}
+
+// stageContext archives the build context honoring .dockerignore together with the rendered
+// Dockerfile in dir. Nothing is written to the context and .gcloudignore is not used as
+// gcloud uploads archives as they are.
+func stageContext(request BuildRequest, dir string) (string, error) {
+	contents, err := ioutil.ReadFile(request.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := docker.Archive(request.Context, map[string][]byte{contextDockerfile: contents})
+	if err != nil {
+		return "", err
+	}
+
+	defer archive.Close()
+
+	source := filepath.Join(dir, "context.tar.gz")
+
+	file, err := os.Create(source)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	compressed := gzip.NewWriter(file)
+
+	if _, err := io.Copy(compressed, archive); err != nil {
+		return "", err
+	}
+
+	if err := compressed.Close(); err != nil {
+		return "", err
+	}
+
+	return source, file.Close()
+}
@@ -0,0 +1,114 @@
+package containers
+
+import (
+	"github.com/wendigo/gcp-builder/docker"
+	"io"
+	"io/ioutil"
+)
+
+// contextDockerfile is the name of the rendered Dockerfile inside the build context tarball.
+const contextDockerfile = ".gcp-builder.Dockerfile"
+
+// daemonBuilder builds through the Docker Engine API. Images with build secrets are built
+// with the docker CLI as secrets require BuildKit sessions.
+type daemonBuilder struct {
+	client *Client
+}
+
+func (b *daemonBuilder) Name() string {
+	return "docker"
+}
+
+func (b *daemonBuilder) PushesOnBuild() bool {
+	return false
+}
+
+func (b *daemonBuilder) Build(request BuildRequest, output io.Writer) (Result, error) {
+	result := Result{Tag: request.Tag, Builder: b.Name(), Tags: request.Tags}
+
+	if request.Cache != nil {
+		if request.Cache.Export == CacheExportRegistry {
+			return result, unsupportedOption(b.Name(), "registry cache export")
+		}
+
+		request.Options.CacheFrom = b.pullCache(request.Cache.From, output)
+
+		// the cache tag is pushed together with the image
+		if request.Cache.Export == CacheExportInline {
+			result.Tags = append(result.Tags, request.Cache.Ref)
+		}
+	}
+
+	if len(request.Secrets) > 0 {
+		return result, b.buildWithCli(request, result.Tags, output)
+	}
+
+	contents, err := ioutil.ReadFile(request.Dockerfile)
+	if err != nil {
+		return result, err
+	}
+
+	archive, err := docker.Archive(request.Context, map[string][]byte{contextDockerfile: contents})
+	if err != nil {
+		return result, err
+	}
+
+	defer archive.Close()
+
+	options := request.Options
+	options.Tags = append([]string{request.Tag}, result.Tags...)
+	options.Dockerfile = contextDockerfile
+
+	if auth, found, err := b.client.credentials(request.Tag); err != nil {
+		return result, err
+	} else if found {
+		options.Registries = map[string]docker.AuthConfig{auth.ServerAddress: auth}
+	}
+
+	_, err = b.client.docker.Build(archive, options, output)
+
+	return result, err
+}
+
+// pullCache pulls cache images as the daemon only uses local images as cache sources.
+// Missing cache images are skipped.
+func (b *daemonBuilder) pullCache(refs []string, output io.Writer) []string {
+	pulled := make([]string, 0)
+
+	for _, ref := range refs {
+		auth, _, err := b.client.credentials(ref)
+		if err == nil {
+			err = b.client.docker.Pull(ref, auth, output)
+		}
+
+		if err != nil {
+			b.client.logger.Printf("Cache image %s is not available: %s", ref, err)
+			continue
+		}
+
+		pulled = append(pulled, ref)
+	}
+
+	return pulled
+}
+
+func (b *daemonBuilder) buildWithCli(request BuildRequest, tags []string, output io.Writer) error {
+	args := []string{"build", "--file", request.Dockerfile, "-t", request.Tag}
+
+	for _, tag := range tags {
+		args = append(args, "-t", tag)
+	}
+
+	if request.Cache != nil && request.Cache.Export == CacheExportInline {
+		// BuildKit uses an image as cache source only when it carries inline cache metadata
+		args = append(args, "--build-arg", "BUILDKIT_INLINE_CACHE=1")
+	}
+
+	args = append(args, buildFlags(request.Options, request.Secrets)...)
+
+	// build secrets are only supported by BuildKit
+	out, err := b.client.gcloud.CaptureCommandWithEnv("docker", append(args, request.Context), []string{"DOCKER_BUILDKIT=1"})
+	output.Write(out)
+
+	return err
+}
@@ -0,0 +1,92 @@
+package containers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/wendigo/gcp-builder/docker"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const contentHashLabel = builderLabelPrefix + ".content-hash"
+const contentTagPrefix = "content-"
+
+// contentHash is a deterministic hash of the build context (honoring .dockerignore), the rendered
+// Dockerfile and options changing the image. Labels are left out as they differ between commits.
+func contentHash(dir string, dockerfile []byte, options docker.BuildOptions) (string, error) {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "dockerfile\x00%d\x00", len(dockerfile))
+	hash.Write(dockerfile)
+
+	keys := make([]string, 0)
+
+	for key := range options.BuildArgs {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(hash, "arg\x00%s=%s\x00", key, options.BuildArgs[key])
+	}
+
+	fmt.Fprintf(hash, "target\x00%s\x00platform\x00%s\x00", options.Target, options.Platform)
+
+	ignore, err := docker.ReadIgnore(dir)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := ignore.Files(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range files {
+		if err := hashFile(hash, dir, name); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(hash io.Writer, dir, name string) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(hash, "file\x00%s\x00%o\x00", name, info.Mode())
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "%s\x00", link)
+	case info.Mode().IsRegular():
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		fmt.Fprintf(hash, "%d\x00", info.Size())
+
+		if _, err := io.Copy(hash, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,7 +0,0 @@
-package containers
-
-type inspect []inspectedImage
-
-type inspectedImage struct {
-	RepoDigests []string `json:"RepoDigests"`
-}
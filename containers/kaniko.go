@@ -0,0 +1,110 @@
+package containers
+
+import (
+	"fmt"
+	"github.com/wendigo/gcp-builder/gcloud"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// kanikoBuilder builds and pushes without a Docker daemon using the kaniko executor binary.
+// Credentials are read from the docker config, Google registries also work with application
+// default credentials.
+type kanikoBuilder struct {
+	gcloud *gcloud.Client
+}
+
+func (b *kanikoBuilder) Name() string {
+	return "kaniko"
+}
+
+func (b *kanikoBuilder) PushesOnBuild() bool {
+	return true
+}
+
+func (b *kanikoBuilder) Build(request BuildRequest, output io.Writer) (Result, error) {
+	result := Result{Tag: request.Tag, Builder: b.Name()}
+
+	digestFile, err := ioutil.TempFile("", "gcp-builder-kaniko")
+	if err != nil {
+		return result, err
+	}
+
+	digestFile.Close()
+
+	defer os.Remove(digestFile.Name())
+
+	args, err := b.args(request, digestFile.Name())
+	if err != nil {
+		return result, err
+	}
+
+	out, err := b.gcloud.CaptureCommand("executor", args)
+	output.Write(out)
+
+	if err != nil {
+		return result, err
+	}
+
+	digest, err := ioutil.ReadFile(digestFile.Name())
+	if err != nil {
+		return result, err
+	}
+
+	result.Digest = strings.TrimSpace(string(digest))
+	result.Pushed = true
+
+	return result, nil
+}
+
+func (b *kanikoBuilder) args(request BuildRequest, digestFile string) ([]string, error) {
+	if len(request.Secrets) > 0 {
+		return nil, unsupportedOption(b.Name(), "secrets")
+	}
+
+	if request.Options.NetworkMode != "" {
+		return nil, unsupportedOption(b.Name(), "network")
+	}
+
+	context, err := filepath.Abs(request.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	dockerfile, err := filepath.Abs(request.Dockerfile)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"--context", fmt.Sprintf("dir://%s", context),
+		"--dockerfile", dockerfile,
+		"--destination", request.Tag,
+		"--digest-file", digestFile,
+	}
+
+	for _, tag := range request.Tags {
+		args = append(args, "--destination", tag)
+	}
+
+	// kaniko keeps cached layers in a repository instead of using images as cache sources
+	if request.Cache != nil {
+		args = append(args, "--cache=true", "--cache-repo", request.Cache.Repository)
+	}
+
+	// kaniko names the platform flag differently
+	options := request.Options
+	options.Platform = ""
+	options.CacheFrom = nil
+
+	args = append(args, buildFlags(options, nil)...)
+
+	if request.Options.Platform != "" {
+		args = append(args, "--custom-platform", request.Options.Platform)
+	}
+
+	return args, nil
+}
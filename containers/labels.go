@@ -0,0 +1,44 @@
+package containers
+
+import (
+	"fmt"
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/project"
+	"time"
+)
+
+const ociLabelPrefix = "org.opencontainers.image"
+const builderLabelPrefix = "gcp-builder"
+
+// defaultLabels returns labels added to every image. OCI labels are always set while
+// gcp-builder.* labels can be disabled per image with builderLabels: false.
+// The creation date is taken from the commit so rebuilding the same commit yields the same image.
+func defaultLabels(context *kubernetes.Context, image project.Image) map[string]string {
+	created := context.Commit.When
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	labels := map[string]string{
+		ociLabelPrefix + ".revision": context.Commit.Hash,
+		ociLabelPrefix + ".source":   context.Build.Repository,
+		ociLabelPrefix + ".version":  context.Version.String(),
+		ociLabelPrefix + ".created":  created.UTC().Format(time.RFC3339),
+		ociLabelPrefix + ".title":    fmt.Sprintf("%s/%s", context.Config.Project.FullName(), image.Name),
+		ociLabelPrefix + ".url":      context.Config.Project.Url,
+	}
+
+	if image.BuilderLabels == nil || *image.BuilderLabels {
+		labels[builderLabelPrefix+".environment"] = context.CurrentEnvironment.Name
+		labels[builderLabelPrefix+".build-number"] = context.Build.Number
+		labels[builderLabelPrefix+".build-url"] = context.Build.Url
+	}
+
+	for key, value := range labels {
+		if value == "" {
+			delete(labels, key)
+		}
+	}
+
+	return labels
+}
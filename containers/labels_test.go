@@ -0,0 +1,79 @@
+package containers
+
+import (
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/platforms"
+	"github.com/wendigo/gcp-builder/project"
+	"testing"
+	"time"
+)
+
+func newLabelsContext(t *testing.T) *kubernetes.Context {
+	version, err := project.ParseVersion("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configuration := &project.Configuration{
+		Project:      project.Project{Name: "app", Domain: "d", Context: "c"},
+		Environments: []*project.Environment{{Name: "test"}},
+		Images:       []project.Image{{Name: "api"}},
+	}
+
+	ctx, err := kubernetes.NewContext(configuration, "test", version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.Commit = platforms.Commit{Hash: "0123456789abcdef", When: time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))}
+	ctx.Build = kubernetes.Build{Number: "42", Url: "https://ci.example.com/42", Repository: "https://git.example.com/app"}
+
+	return ctx
+}
+
+func TestDefaultLabels(t *testing.T) {
+	ctx := newLabelsContext(t)
+	labels := defaultLabels(ctx, ctx.Images()[0])
+
+	expected := map[string]string{
+		"org.opencontainers.image.revision": "0123456789abcdef",
+		"org.opencontainers.image.source":   "https://git.example.com/app",
+		"org.opencontainers.image.version":  "1.2.3",
+		"org.opencontainers.image.created":  "2020-01-02T02:04:05Z",
+		"org.opencontainers.image.title":    "d-c-app/api",
+		"gcp-builder.environment":           "test",
+		"gcp-builder.build-number":          "42",
+		"gcp-builder.build-url":             "https://ci.example.com/42",
+	}
+
+	if len(labels) != len(expected) {
+		t.Errorf("expected labels %v, got %v", expected, labels)
+	}
+
+	for key, value := range expected {
+		if labels[key] != value {
+			t.Errorf("expected label %s=%s, got %q", key, value, labels[key])
+		}
+	}
+}
+
+func TestDefaultLabelsWithoutBuilderLabels(t *testing.T) {
+	ctx := newLabelsContext(t)
+	ctx.Config.Project.Url = "https://example.com"
+
+	disabled := false
+	image := ctx.Images()[0]
+	image.BuilderLabels = &disabled
+
+	labels := defaultLabels(ctx, image)
+
+	for _, key := range []string{"gcp-builder.environment", "gcp-builder.build-number", "gcp-builder.build-url"} {
+		if _, exists := labels[key]; exists {
+			t.Errorf("expected %s to be disabled, got %v", key, labels)
+		}
+	}
+
+	if labels["org.opencontainers.image.url"] != "https://example.com" || labels["org.opencontainers.image.revision"] != "0123456789abcdef" {
+		t.Errorf("expected OCI labels to be kept, got %v", labels)
+	}
+}
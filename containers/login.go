@@ -0,0 +1,61 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/registry"
+	"os/exec"
+)
+
+// Login authenticates the docker CLI to registries of the images so command line builders
+// (buildx, kaniko, builds with secrets) can push. Registries with configured credentials are
+// logged in with docker login, Google registries use the gcloud credential helper and the
+// rest rely on the existing docker config.
+func (c *Client) Login(context *kubernetes.Context) error {
+	hosts := make([]string, 0)
+
+	for _, image := range context.Images() {
+		if host := registry.Host(context.Registry(image.Name)); !contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, host := range hosts {
+		auth, found, err := c.configuredCredentials(host)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case found:
+			if _, err := exec.LookPath("docker"); err != nil {
+				c.logger.Printf("Docker CLI not found, credentials for %s are used only by gcp-builder", host)
+				continue
+			}
+
+			c.logger.Printf("Logging in to %s registry %s as %s", registry.KindOf(host), host, auth.Username)
+
+			args := []string{"login", "--username", auth.Username, "--password-stdin"}
+
+			// docker login defaults to Docker Hub
+			if host != registry.DockerHub {
+				args = append(args, host)
+			}
+
+			if out, err := c.gcloud.CaptureCommandWithInput("docker", args, []byte(auth.Password)); err != nil {
+				return errors.New(fmt.Sprintf("RegistryLoginFailed(%s): %s", host, out))
+			}
+		case registry.IsGoogle(host):
+			c.logger.Printf("Configuring gcloud credential helper for %s registry %s", registry.KindOf(host), host)
+
+			if out, err := c.gcloud.CaptureCommand("gcloud", []string{"auth", "configure-docker", host, "--quiet"}); err != nil {
+				return errors.New(fmt.Sprintf("RegistryLoginFailed(%s): %s", host, out))
+			}
+		default:
+			c.logger.Printf("Using docker config credentials for %s registry %s", registry.KindOf(host), host)
+		}
+	}
+
+	return nil
+}
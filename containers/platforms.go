@@ -0,0 +1,207 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/registry"
+	"io"
+	"strings"
+)
+
+// platformTag is the tag of a single platform image of a multi-platform build.
+func platformTag(tag, platform string) string {
+	return fmt.Sprintf("%s-%s", tag, project.SanitizeTag(strings.Replace(platform, "/", "-", -1)))
+}
+
+// buildsPlatforms tells whether the builder builds all platforms at once pushing the manifest list itself.
+func buildsPlatforms(builder Builder) bool {
+	return builder.Name() == "buildx"
+}
+
+// buildPlatforms builds every platform as a separate image tagged with platformTag. Images of
+// builders pushing on build are combined into a manifest list right away, images built by the
+// daemon in the push step. Layer cache is only exported by builds of a single platform.
+func (c *Client) buildPlatforms(builder Builder, request BuildRequest, platforms []string, output io.Writer) (Result, error) {
+	result := Result{Tag: request.Tag, Builder: builder.Name(), Tags: request.Tags, Platforms: platforms}
+
+	for _, platform := range platforms {
+		platformRequest := request
+		platformRequest.Tag = platformTag(request.Tag, platform)
+		platformRequest.Tags = nil
+		platformRequest.Options.Platform = platform
+
+		if request.Cache != nil {
+			cache := *request.Cache
+			cache.Export = ""
+			platformRequest.Cache = &cache
+		}
+
+		c.logger.Printf("Building platform %s of %s as %s", platform, request.Tag, platformRequest.Tag)
+
+		built, err := builder.Build(platformRequest, output)
+		if err != nil {
+			return result, err
+		}
+
+		result.Pushed = built.Pushed
+	}
+
+	if !result.Pushed {
+		return result, nil
+	}
+
+	digest, digests, err := c.pushIndex(request.Tag, request.Tags, platforms)
+	if err != nil {
+		return result, err
+	}
+
+	result.Digest = digest
+	result.PlatformDigests = digests
+
+	return result, nil
+}
+
+// pushPlatforms pushes platform images built by the daemon followed by their manifest list.
+func (c *Client) pushPlatforms(result *Result, output io.Writer) error {
+	for _, platform := range result.Platforms {
+		reference := platformTag(result.Tag, platform)
+
+		c.logger.Printf("Pushing container %s", reference)
+
+		if _, err := c.push(reference, output); err != nil {
+			return err
+		}
+	}
+
+	digest, digests, err := c.pushIndex(result.Tag, result.Tags, result.Platforms)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(output, "%s is a manifest list of %s with digest %s\n", result.Tag, strings.Join(result.Platforms, ", "), digest)
+
+	result.Digest = digest
+	result.PlatformDigests = digests
+
+	return nil
+}
+
+// pushIndex combines pushed platform images into a manifest list stored under the tag and
+// its additional tags. The digest of the list and digests of platform images are returned.
+func (c *Client) pushIndex(tag string, tags []string, platforms []string) (string, map[string]string, error) {
+	descriptors := make([]registry.Descriptor, 0)
+	digests := make(map[string]string)
+
+	for _, platform := range platforms {
+		reference := platformTag(tag, platform)
+
+		manifest, err := c.registry.Manifest(reference)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if manifest.IsIndex() {
+			return "", nil, errors.New(fmt.Sprintf("UnexpectedManifestList(%s): platform image must be a single manifest", reference))
+		}
+
+		descriptors = append(descriptors, registry.Descriptor{
+			MediaType: manifest.MediaType,
+			Digest:    manifest.Digest,
+			Size:      len(manifest.Body),
+			Platform:  platform,
+		})
+
+		digests[platform] = manifest.Digest
+	}
+
+	index, err := registry.NewIndex(descriptors)
+	if err != nil {
+		return "", nil, err
+	}
+
+	digest := ""
+
+	for _, reference := range append([]string{tag}, tags...) {
+		c.logger.Printf("Pushing manifest list %s", reference)
+
+		pushed, err := c.registry.PutManifest(reference, index)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if reference == tag {
+			digest = pushed
+		}
+	}
+
+	return digest, digests, nil
+}
+
+// samePlatforms compares platform images of a pushed manifest list with images built locally.
+func (c *Client) samePlatforms(tag string, remote *registry.Manifest, platforms []string) (bool, error) {
+	if !remote.IsIndex() {
+		return false, nil
+	}
+
+	ref, err := registry.ParseReference(tag)
+	if err != nil {
+		return false, err
+	}
+
+	remotePlatforms := remote.Platforms()
+
+	for _, platform := range platforms {
+		digest, exists := remotePlatforms[platform]
+		if !exists {
+			return false, nil
+		}
+
+		manifest, err := c.registry.Manifest(ref.WithReference(digest).String())
+		if err != nil {
+			return false, err
+		}
+
+		local, err := c.docker.Inspect(platformTag(tag, platform))
+		if err != nil {
+			return false, err
+		}
+
+		if manifest.ConfigDigest() != local.Id {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// verifyPlatformTags checks tags of platform images of a release before they are pushed. They
+// are immutable like the manifest list, so a tag holding a different image fails the push also
+// when the manifest list itself was never pushed.
+func (c *Client) verifyPlatformTags(tag string, platforms []string) error {
+	for _, platform := range platforms {
+		reference := platformTag(tag, platform)
+
+		remote, err := c.remoteManifest(reference)
+		if err != nil {
+			return err
+		}
+
+		if remote == nil {
+			continue
+		}
+
+		local, err := c.docker.Inspect(reference)
+		if err != nil {
+			return err
+		}
+
+		if remote.ConfigDigest() != local.Id {
+			if _, err := c.pushedRevision(tag, reference, remote); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/docker"
+	"github.com/wendigo/gcp-builder/registry"
+	"strings"
+)
+
+// repositoryOf strips the tag from an image reference.
+func repositoryOf(reference string) string {
+	if colon := strings.LastIndex(reference, ":"); colon > strings.LastIndex(reference, "/") {
+		return reference[:colon]
+	}
+
+	return reference
+}
+
+// remoteManifest fetches the manifest of a tag from the registry. Nil is returned when the tag
+// does not exist.
+func (c *Client) remoteManifest(tag string) (*registry.Manifest, error) {
+	manifest, err := c.registry.Manifest(tag)
+	if registry.IsNotFound(err) {
+		return nil, nil
+	}
+
+	return manifest, err
+}
+
+// remoteDigest resolves the digest of a pushed tag with a manifest HEAD request.
+func (c *Client) remoteDigest(tag string) (string, error) {
+	digest, err := c.registry.Digest(tag)
+	if registry.IsNotFound(err) {
+		return "", errors.New(fmt.Sprintf("Image with tag %s was not pushed so remote digest cannot be determined", tag))
+	}
+
+	return digest, err
+}
+
+// retag points the target tag to the manifest of the source tag without pulling the image.
+// Both tags have to be in the same repository.
+func (c *Client) retag(source, target string) (string, error) {
+	manifest, err := c.registry.Manifest(source)
+	if err != nil {
+		return "", err
+	}
+
+	c.logger.Printf("Tagging %s as %s in the registry", source, target)
+
+	return c.registry.PutManifest(target, manifest)
+}
+
+func (c *Client) accessToken() (string, error) {
+	token, err := c.gcloud.CaptureCommand("gcloud", []string{"auth", "print-access-token"})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
+
+// credentials resolves registry credentials for the image reference from variables configured
+// for the registry, docker config and credential helpers, falling back to the gcloud access token
+// for Google registries.
+func (c *Client) credentials(tag string) (docker.AuthConfig, bool, error) {
+	host := registry.Host(tag)
+
+	auth, found, err := c.configuredCredentials(host)
+	if err != nil || found {
+		return auth, found, err
+	}
+
+	auth, found, err = docker.Credentials(host)
+	if err != nil || found {
+		return auth, found, err
+	}
+
+	if !registry.IsGoogle(host) {
+		return docker.AuthConfig{ServerAddress: host}, false, nil
+	}
+
+	token, err := c.accessToken()
+	if err != nil {
+		return docker.AuthConfig{}, false, err
+	}
+
+	return docker.AuthConfig{Username: "oauth2accesstoken", Password: token, ServerAddress: host}, true, nil
+}
+
+// configuredCredentials reads credentials from variables of a registry configured for the host.
+func (c *Client) configuredCredentials(host string) (docker.AuthConfig, bool, error) {
+	for _, configured := range c.registries {
+		if registry.Host(configured.Url) != host {
+			continue
+		}
+
+		username, password, found, err := configured.Credentials()
+		if err != nil || found {
+			return docker.AuthConfig{Username: username, Password: password, ServerAddress: host}, found, err
+		}
+	}
+
+	return docker.AuthConfig{}, false, nil
+}
+
+// registryCredentials answers registry API authentication challenges with the same
+// credentials used for pushing.
+func (c *Client) registryCredentials(host string) (registry.Credentials, error) {
+	auth, _, err := c.credentials(host)
+	if err != nil {
+		return registry.Credentials{}, err
+	}
+
+	return registry.Credentials{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+	}, nil
+}
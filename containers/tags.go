@@ -0,0 +1,42 @@
+package containers
+
+import (
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/project"
+	"strings"
+)
+
+// policyTags renders tag templates of the image, or of the environment when the image has none,
+// which apply to the current branch and git tag. Templates rendering to an empty string are skipped.
+func policyTags(context *kubernetes.Context, image project.Image) ([]string, error) {
+	policies := image.Tags
+	if len(policies) == 0 {
+		policies = context.CurrentEnvironment.Tags
+	}
+
+	tags := make([]string, 0)
+	versionTag := context.ContainerPath(image.Name)
+
+	for _, policy := range policies {
+		if !policy.Applies(context.Build.Branch, context.Build.Tag) {
+			continue
+		}
+
+		name, err := context.Render(policy.Name)
+		if err != nil {
+			return tags, err
+		}
+
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+
+		tag := context.ContainerVersion(image.Name, strings.TrimSpace(name))
+
+		if tag != versionTag && !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,289 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/docker"
+	"github.com/wendigo/gcp-builder/kubernetes"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultTestTimeout = 60 * time.Second
+
+// testEntrypoint is never run, it lets the daemon create containers of images without a command.
+const testEntrypoint = "/gcp-builder-test"
+
+// TestContainer runs checks declared for the image against the built image. Failed checks are
+// returned as results, an error means the checks could not be run.
+func (c *Client) TestContainer(context *kubernetes.Context, image project.Image) ([]report.Test, error) {
+	tests := make([]report.Test, 0)
+
+	if image.Tests == nil {
+		return tests, nil
+	}
+
+	reference, err := c.LocalReference(context, image)
+	if err != nil {
+		return tests, err
+	}
+
+	c.logger.Printf("Testing container %s [%s]", image.Name, reference)
+
+	inspected, err := c.docker.Inspect(reference)
+	if err != nil {
+		return tests, err
+	}
+
+	if image.Tests.Metadata != nil {
+		tests = append(tests, metadataTests(*image.Tests.Metadata, inspected.Config)...)
+	}
+
+	if len(image.Tests.Files) > 0 {
+		results, err := c.fileTests(reference, image.Tests.Files)
+		if err != nil {
+			return tests, err
+		}
+
+		tests = append(tests, results...)
+	}
+
+	for _, command := range image.Tests.Commands {
+		result, err := c.commandTest(reference, command)
+		if err != nil {
+			return tests, err
+		}
+
+		tests = append(tests, result)
+	}
+
+	return tests, nil
+}
+
+// LocalReference returns the image in the local daemon to test or scan. Images which are not in the
+// daemon (staged or pushed by remote builders or reused) are pulled, multi-platform images are
+// represented by the platform of the host.
+func (c *Client) LocalReference(context *kubernetes.Context, image project.Image) (string, error) {
+	tag := context.ContainerPath(image.Name)
+	result, exists := c.results[tag]
+
+	switch {
+	case exists && result.Staged != "":
+		return c.pull(result.Staged)
+	case exists && len(result.Platforms) > 0 && !result.Pushed:
+		return platformTag(tag, hostPlatform(result.Platforms)), nil
+	case exists && !result.Pushed && result.Reused == "":
+		return tag, nil
+	case !exists:
+		if _, err := c.docker.Inspect(tag); err == nil {
+			return tag, nil
+		}
+	}
+
+	if exists && !result.Pushed && result.Reused != "" {
+		return c.pull(result.Reused)
+	}
+
+	return c.pull(tag)
+}
+
+func (c *Client) pull(reference string) (string, error) {
+	auth, _, err := c.credentials(reference)
+	if err != nil {
+		return "", err
+	}
+
+	return reference, c.docker.Pull(reference, auth, os.Stderr)
+}
+
+// hostPlatform returns the platform matching the architecture gcp-builder runs on, falling
+// back to the first one.
+func hostPlatform(platforms []string) string {
+	for _, platform := range platforms {
+		if strings.HasPrefix(platform, fmt.Sprintf("linux/%s", runtime.GOARCH)) {
+			return platform
+		}
+	}
+
+	return platforms[0]
+}
+
+func metadataTests(test project.MetadataTest, config docker.ImageConfig) []report.Test {
+	tests := make([]report.Test, 0)
+
+	for _, port := range test.ExposedPorts {
+		if !strings.Contains(port, "/") {
+			port = fmt.Sprintf("%s/tcp", port)
+		}
+
+		_, exposed := config.ExposedPorts[port]
+		tests = append(tests, testResult(fmt.Sprintf("metadata: exposed port %s", port), exposed, "port is not exposed"))
+	}
+
+	if len(test.Entrypoint) > 0 {
+		tests = append(tests, testResult(
+			"metadata: entrypoint",
+			reflect.DeepEqual(test.Entrypoint, config.Entrypoint),
+			fmt.Sprintf("entrypoint is %q", config.Entrypoint),
+		))
+	}
+
+	if test.NonRoot {
+		user := strings.SplitN(config.User, ":", 2)[0]
+		root := user == "" || user == "root" || user == "0"
+
+		tests = append(tests, testResult("metadata: non-root user", !root, "image runs as root"))
+	}
+
+	return tests
+}
+
+// fileTests checks paths in a created container which is never started.
+func (c *Client) fileTests(reference string, files []project.FileTest) ([]report.Test, error) {
+	tests := make([]report.Test, 0)
+
+	id, err := c.docker.CreateContainer(docker.ContainerConfig{Image: reference, Entrypoint: []string{testEntrypoint}})
+	if err != nil {
+		return tests, err
+	}
+
+	defer c.docker.RemoveContainer(id)
+
+	for _, file := range files {
+		expected := file.Exists == nil || *file.Exists
+
+		exists, err := c.docker.PathExists(id, file.Path)
+		if err != nil {
+			return tests, err
+		}
+
+		if expected {
+			tests = append(tests, testResult(fmt.Sprintf("file: %s exists", file.Path), exists, "file does not exist"))
+		} else {
+			tests = append(tests, testResult(fmt.Sprintf("file: %s is absent", file.Path), !exists, "file exists"))
+		}
+	}
+
+	return tests, nil
+}
+
+// commandTest runs the command as the entrypoint of the image, containers running longer
+// than the timeout are killed.
+func (c *Client) commandTest(reference string, test project.CommandTest) (report.Test, error) {
+	name := test.Name
+	if name == "" {
+		name = strings.Join(test.Command, " ")
+	}
+
+	name = fmt.Sprintf("command: %s", name)
+
+	if len(test.Command) == 0 {
+		return testResult(name, false, "command is empty"), nil
+	}
+
+	timeout := defaultTestTimeout
+
+	if test.Timeout != "" {
+		parsed, err := time.ParseDuration(test.Timeout)
+		if err != nil {
+			return report.Test{}, errors.New(fmt.Sprintf("InvalidTestTimeout(%s): %s", test.Timeout, err))
+		}
+
+		timeout = parsed
+	}
+
+	env := make([]string, 0)
+
+	for key, value := range test.Env {
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	sort.Strings(env)
+
+	id, err := c.docker.CreateContainer(docker.ContainerConfig{Image: reference, Entrypoint: test.Command, Env: env})
+	if err != nil {
+		return report.Test{}, err
+	}
+
+	defer c.docker.RemoveContainer(id)
+
+	if err := c.docker.StartContainer(id); err != nil {
+		return testResult(name, false, err.Error()), nil
+	}
+
+	type exit struct {
+		code int
+		err  error
+	}
+
+	exited := make(chan exit, 1)
+
+	go func() {
+		code, err := c.docker.WaitContainer(id)
+		exited <- exit{code, err}
+	}()
+
+	var code int
+
+	select {
+	case result := <-exited:
+		if result.err != nil {
+			return report.Test{}, result.err
+		}
+
+		code = result.code
+	case <-time.After(timeout):
+		c.docker.KillContainer(id)
+		return testResult(name, false, fmt.Sprintf("timed out after %s", timeout)), nil
+	}
+
+	output, err := c.docker.ContainerLogs(id)
+	if err != nil {
+		return report.Test{}, err
+	}
+
+	return checkCommand(name, test, code, string(output)), nil
+}
+
+// checkCommand compares the exit code and output with expectations of the test.
+func checkCommand(name string, test project.CommandTest, code int, output string) report.Test {
+	failures := make([]string, 0)
+
+	if code != test.ExitCode {
+		failures = append(failures, fmt.Sprintf("exit code %d, expected %d", code, test.ExitCode))
+	}
+
+	for _, expression := range test.ExpectedOutput {
+		if matched, err := regexp.MatchString(expression, output); err != nil {
+			failures = append(failures, fmt.Sprintf("invalid expression %q: %s", expression, err))
+		} else if !matched {
+			failures = append(failures, fmt.Sprintf("output does not match %q", expression))
+		}
+	}
+
+	for _, expression := range test.ExcludedOutput {
+		if matched, err := regexp.MatchString(expression, output); err != nil {
+			failures = append(failures, fmt.Sprintf("invalid expression %q: %s", expression, err))
+		} else if matched {
+			failures = append(failures, fmt.Sprintf("output matches %q", expression))
+		}
+	}
+
+	return testResult(name, len(failures) == 0, strings.Join(failures, "; "))
+}
+
+// testResult drops the message of passed checks.
+func testResult(name string, passed bool, message string) report.Test {
+	if passed {
+		message = ""
+	}
+
+	return report.Test{Name: name, Passed: passed, Message: message}
+}
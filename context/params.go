@@ -2,10 +2,13 @@ package context
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/wendigo/gcp-builder/kubernetes"
 	"github.com/wendigo/gcp-builder/platforms"
 	"github.com/wendigo/gcp-builder/project"
-	"html/template"
+	"github.com/wendigo/gcp-builder/templates"
+	"strings"
+	"text/template"
 )
 
 type Params map[string]interface{}
@@ -25,7 +28,7 @@ func (p Params) Merge(l Params) Params {
 }
 
 func From(context *kubernetes.Context, platform platforms.Platform) Params {
-	return Params{
+	params := Params{
 		"Environment":       context.CurrentEnvironment.Name,
 		"KubernetesCluster": context.CurrentEnvironment.Kubernetes.Cluster,
 		"KubernetesZone":    context.CurrentEnvironment.Kubernetes.Zone,
@@ -34,17 +37,51 @@ func From(context *kubernetes.Context, platform platforms.Platform) Params {
 		"BuildUrl":          platform.BuildUrl(),
 		"BuildRepository":   platform.RepositoryUrl(),
 		"BuildPlatform":     platform.Name(),
-		"BuildNumber":       platform.CurrentBuildNumber(),
+		"BuildNumber":       buildNumber(platform),
 		"BuildBranch":       platform.CurrentBranch(),
 		"BuildCommit":       platform.CurrentCommit(),
-		"BuildTag":          platform.CurrentTag(),
-		"BuildVersion":      context.Version,
+		"BuildTag":          strings.Join(platform.CurrentTags(), ", "),
+		"BuildVersion":      context.Version.String(),
 		"ProjectName":       context.Config.Project.Name,
 		"ProjectDomain":     context.Config.Project.Domain,
 		"ProjectContext":    context.Config.Project.Context,
-		"ProjectVersion":    context.Version,
+		"ProjectVersion":    context.Version.String(),
+		"ProjectVersionTag": context.Version.Tag(),
 		"ProjectFullName":   context.Config.Project.FullName(),
+		"CommitAuthor":      context.Commit.Author,
+		"CommitCommitter":   context.Commit.Committer,
+		"CommitSubject":     context.Commit.Subject,
+		"CommitMessage":     context.Commit.Message,
+		"ImageRepositories": imageRepositories(context),
+	}
+
+	params.Refresh(context)
+
+	return params
+}
+
+// buildNumber returns the build number of the platform, empty when it's not available.
+func buildNumber(platform platforms.Platform) string {
+	number, _ := platform.CurrentBuildNumber()
+	return number
+}
+
+// Refresh updates params that are resolved after the release has started.
+// Notification providers share the map so they see the updated values.
+func (p Params) Refresh(context *kubernetes.Context) {
+	p["DeployedCommit"] = context.DeployedCommit
+	p["Changelog"] = context.Changelog
+}
+
+// imageRepositories maps image names to repositories in the registries they are pushed to.
+func imageRepositories(context *kubernetes.Context) map[string]string {
+	repositories := make(map[string]string)
+
+	for _, image := range context.Images() {
+		repositories[image.Name] = fmt.Sprintf("%s/%s/%s", context.Registry(image.Name), context.Config.Project.FullName(), image.Name)
 	}
+
+	return repositories
 }
 
 func FromImage(image project.Image) Params {
@@ -56,7 +93,7 @@ func FromImage(image project.Image) Params {
 }
 
 func (p Params) ExpandTemplate(tpl string) string {
-	tmpl, err := template.New("slack-template").Parse(tpl)
+	tmpl, err := template.New("notification").Funcs(templates.Functions()).Parse(tpl)
 	if err != nil {
 		return err.Error()
 	}
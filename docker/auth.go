@@ -0,0 +1,180 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// AuthConfig is sent in the X-Registry-Auth header.
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+func (a AuthConfig) encode() (string, error) {
+	contents, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(contents), nil
+}
+
+type configFile struct {
+	Auths       map[string]configAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+type configAuth struct {
+	Auth          string `json:"auth"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type helperCredentials struct {
+	Username string `json:"Username"`
+	Secret   string `json:"Secret"`
+}
+
+// Credentials looks up credentials for the registry host in the docker config file
+// ($DOCKER_CONFIG/config.json or ~/.docker/config.json), asking the credential helper
+// configured for the host or the default credentials store first.
+func Credentials(host string) (AuthConfig, bool, error) {
+	config, err := readConfigFile()
+	if err != nil {
+		return AuthConfig{}, false, err
+	}
+
+	helper := config.CredsStore
+	if registryHelper, exists := config.CredHelpers[host]; exists {
+		helper = registryHelper
+	}
+
+	if helper != "" {
+		server := host
+		if normalizeHost(host) == "docker.io" {
+			server = dockerHubAuthKey
+		}
+
+		auth, found, err := helperLookup(helper, server)
+		if err != nil || found {
+			return auth, found, err
+		}
+	}
+
+	for key, auth := range config.Auths {
+		if normalizeHost(key) != normalizeHost(host) {
+			continue
+		}
+
+		result := AuthConfig{ServerAddress: host, IdentityToken: auth.IdentityToken}
+
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return AuthConfig{}, false, errors.New(fmt.Sprintf("InvalidDockerConfigAuth(%s)", key))
+			}
+
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return AuthConfig{}, false, errors.New(fmt.Sprintf("InvalidDockerConfigAuth(%s)", key))
+			}
+
+			result.Username = parts[0]
+			result.Password = parts[1]
+		}
+
+		return result, true, nil
+	}
+
+	return AuthConfig{}, false, nil
+}
+
+func readConfigFile() (configFile, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return configFile{}, err
+		}
+
+		dir = filepath.Join(home, ".docker")
+	}
+
+	config := configFile{}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return config, errors.New(fmt.Sprintf("InvalidDockerConfig(%s): %s", filepath.Join(dir, "config.json"), err))
+	}
+
+	return config, nil
+}
+
+// helperLookup runs docker-credential-<helper> get. Missing credentials are not an error.
+func helperLookup(helper, host string) (AuthConfig, bool, error) {
+	command := exec.Command(fmt.Sprintf("docker-credential-%s", helper), "get")
+	command.Stdin = strings.NewReader(host)
+
+	stderr := &bytes.Buffer{}
+	command.Stderr = stderr
+
+	output, err := command.Output()
+	if err != nil {
+		if strings.Contains(string(output)+stderr.String(), "credentials not found") {
+			return AuthConfig{}, false, nil
+		}
+
+		return AuthConfig{}, false, errors.New(fmt.Sprintf("CredentialHelperFailed(%s, %s): %s", helper, host, err))
+	}
+
+	credentials := helperCredentials{}
+
+	if err := json.Unmarshal(output, &credentials); err != nil {
+		return AuthConfig{}, false, errors.New(fmt.Sprintf("CredentialHelperFailed(%s, %s): %s", helper, host, err))
+	}
+
+	if credentials.Username == "<token>" {
+		return AuthConfig{ServerAddress: host, IdentityToken: credentials.Secret}, true, nil
+	}
+
+	return AuthConfig{ServerAddress: host, Username: credentials.Username, Password: credentials.Secret}, true, nil
+}
+
+func normalizeHost(address string) string {
+	if address == dockerHubAuthKey {
+		return "docker.io"
+	}
+
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+
+	if slash := strings.Index(address, "/"); slash != -1 {
+		address = address[:slash]
+	}
+
+	if address == "index.docker.io" || address == "registry-1.docker.io" {
+		return "docker.io"
+	}
+
+	return address
+}
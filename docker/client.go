@@ -0,0 +1,184 @@
+package docker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/go-homedir"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultHost = "unix:///var/run/docker.sock"
+
+// Client talks to the Docker Engine API over a Unix socket or TCP (optionally with TLS)
+// configured with the same DOCKER_HOST, DOCKER_TLS_VERIFY, DOCKER_CERT_PATH and
+// DOCKER_API_VERSION variables as the docker CLI.
+type Client struct {
+	http     *http.Client
+	endpoint string
+	version  string
+	logger   *log.Logger
+}
+
+func NewFromEnvironment() (*Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultHost
+	}
+
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("InvalidDockerHost(%s): %s", host, err))
+	}
+
+	transport := &http.Transport{}
+	client := &Client{
+		http:    &http.Client{Transport: transport},
+		version: os.Getenv("DOCKER_API_VERSION"),
+		logger: log.New(
+			os.Stdout, "[docker] ", log.Lmicroseconds,
+		),
+	}
+
+	switch parsed.Scheme {
+	case "unix":
+		socket := parsed.Path
+
+		transport.Dial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		}
+
+		client.endpoint = "http://docker"
+	case "tcp", "http", "https":
+		config, err := tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		if config != nil {
+			transport.TLSClientConfig = config
+			client.endpoint = fmt.Sprintf("https://%s", parsed.Host)
+		} else {
+			client.endpoint = fmt.Sprintf("http://%s", parsed.Host)
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("InvalidDockerHost(%s): unsupported scheme %s", host, parsed.Scheme))
+	}
+
+	return client, nil
+}
+
+// tlsConfig returns nil when neither DOCKER_TLS_VERIFY nor DOCKER_CERT_PATH is set.
+func tlsConfig() (*tls.Config, error) {
+	verify := os.Getenv("DOCKER_TLS_VERIFY") != ""
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+
+	if !verify && certPath == "" {
+		return nil, nil
+	}
+
+	if certPath == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+
+		certPath = filepath.Join(home, ".docker")
+	}
+
+	config := &tls.Config{InsecureSkipVerify: !verify}
+
+	if ca, err := ioutil.ReadFile(filepath.Join(certPath, "ca.pem")); err == nil {
+		config.RootCAs = x509.NewCertPool()
+
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New(fmt.Sprintf("InvalidCertificate(%s)", filepath.Join(certPath, "ca.pem")))
+		}
+	} else if verify {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem"))
+	if err == nil {
+		config.Certificates = []tls.Certificate{cert}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	if c.version != "" {
+		path = fmt.Sprintf("/v%s%s", strings.TrimPrefix(c.version, "v"), path)
+	}
+
+	if len(query) > 0 {
+		return fmt.Sprintf("%s%s?%s", c.endpoint, path, query.Encode())
+	}
+
+	return c.endpoint + path
+}
+
+// do sends the request and turns error responses into errors. The caller closes the body.
+func (c *Client) do(method, path string, query url.Values, headers map[string]string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.url(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+
+		return nil, responseError(method, path, response)
+	}
+
+	return response, nil
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func responseError(method, path string, response *http.Response) error {
+	contents, _ := ioutil.ReadAll(response.Body)
+	message := errorResponse{}
+
+	if err := json.Unmarshal(contents, &message); err != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(contents))
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return notFoundError{errors.New(fmt.Sprintf("NotFound(%s %s): %s", method, path, message.Message))}
+	}
+
+	return errors.New(fmt.Sprintf("DockerError(%s %s): %s %s", method, path, response.Status, message.Message))
+}
+
+type notFoundError struct {
+	error
+}
+
+func IsNotFound(err error) bool {
+	_, ok := err.(notFoundError)
+	return ok
+}
@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+)
+
+// ContainerConfig creates a container. A non-empty Entrypoint replaces the image entrypoint and command.
+type ContainerConfig struct {
+	Image      string   `json:"Image"`
+	Entrypoint []string `json:"Entrypoint,omitempty"`
+	Cmd        []string `json:"Cmd,omitempty"`
+	Env        []string `json:"Env,omitempty"`
+}
+
+type createResponse struct {
+	Id string `json:"Id"`
+}
+
+type waitResponse struct {
+	StatusCode int `json:"StatusCode"`
+}
+
+func (c *Client) CreateContainer(config ContainerConfig) (string, error) {
+	body, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.do("POST", "/containers/create", nil, map[string]string{"Content-Type": "application/json"}, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	created := createResponse{}
+
+	return created.Id, json.NewDecoder(response.Body).Decode(&created)
+}
+
+func (c *Client) StartContainer(id string) error {
+	return c.discard("POST", fmt.Sprintf("/containers/%s/start", id), nil)
+}
+
+// WaitContainer blocks until the container exits and returns its exit code.
+func (c *Client) WaitContainer(id string) (int, error) {
+	response, err := c.do("POST", fmt.Sprintf("/containers/%s/wait", id), nil, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	wait := waitResponse{}
+
+	return wait.StatusCode, json.NewDecoder(response.Body).Decode(&wait)
+}
+
+func (c *Client) KillContainer(id string) error {
+	return c.discard("POST", fmt.Sprintf("/containers/%s/kill", id), nil)
+}
+
+// RemoveContainer removes the container together with its anonymous volumes.
+func (c *Client) RemoveContainer(id string) error {
+	query := url.Values{}
+	query.Set("force", "1")
+	query.Set("v", "1")
+
+	return c.discard("DELETE", fmt.Sprintf("/containers/%s", id), query)
+}
+
+// ContainerLogs returns stdout and stderr of a container started without a TTY.
+func (c *Client) ContainerLogs(id string) ([]byte, error) {
+	query := url.Values{}
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+
+	response, err := c.do("GET", fmt.Sprintf("/containers/%s/logs", id), query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	return demultiplex(response.Body)
+}
+
+// PathExists checks a path in the container filesystem, the container does not have to be running.
+func (c *Client) PathExists(id, path string) (bool, error) {
+	query := url.Values{}
+	query.Set("path", path)
+
+	err := c.discard("HEAD", fmt.Sprintf("/containers/%s/archive", id), query)
+	if IsNotFound(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (c *Client) discard(method, path string, query url.Values) error {
+	response, err := c.do(method, path, query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	_, err = io.Copy(ioutil.Discard, response.Body)
+
+	return err
+}
+
+// demultiplex joins frames of a stdout/stderr stream: an 8 byte header with the stream type
+// and big endian frame size followed by the frame.
+func demultiplex(reader io.Reader) ([]byte, error) {
+	output := make([]byte, 0)
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			return output, nil
+		} else if err != nil {
+			return output, err
+		}
+
+		frame := make([]byte, binary.BigEndian.Uint32(header[4:]))
+
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return output, err
+		}
+
+		output = append(output, frame...)
+	}
+}
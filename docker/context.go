@@ -0,0 +1,269 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const dockerIgnore = ".dockerignore"
+
+type ignorePattern struct {
+	pattern   *regexp.Regexp
+	exclusion bool
+}
+
+// Ignore holds .dockerignore patterns. Later patterns win, patterns starting with ! re-include files.
+type Ignore []ignorePattern
+
+func ReadIgnore(dir string) (Ignore, error) {
+	file, err := os.Open(filepath.Join(dir, dockerIgnore))
+	if os.IsNotExist(err) {
+		return Ignore{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	patterns := make(Ignore, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		exclusion := strings.HasPrefix(line, "!")
+		line = strings.TrimSpace(strings.TrimPrefix(line, "!"))
+		line = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(line)), "/")
+
+		pattern, err := compileIgnore(line)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("InvalidIgnorePattern(%s): %s", line, err))
+		}
+
+		patterns = append(patterns, ignorePattern{pattern, exclusion})
+	}
+
+	return patterns, scanner.Err()
+}
+
+// compileIgnore translates ** (any number of directories), *, ? and character classes into a
+// regular expression.
+func compileIgnore(pattern string) (*regexp.Regexp, error) {
+	expression := "^"
+
+	for i := 0; i < len(pattern); i++ {
+		switch char := pattern[i]; {
+		case char == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			i++
+
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				i++
+				expression += "(.*/)?"
+			} else {
+				expression += ".*"
+			}
+		case char == '*':
+			expression += "[^/]*"
+		case char == '?':
+			expression += "[^/]"
+		case char == '[':
+			end := strings.Index(pattern[i:], "]")
+			if end < 2 {
+				return nil, errors.New("unterminated character class")
+			}
+
+			class := pattern[i+1 : i+end]
+			i += end
+
+			if strings.HasPrefix(class, "^") || strings.HasPrefix(class, "!") {
+				expression += "[^/" + class[1:] + "]"
+			} else {
+				expression += "[" + class + "]"
+			}
+		case char == '\\' && i+1 < len(pattern):
+			i++
+			expression += regexp.QuoteMeta(string(pattern[i]))
+		default:
+			expression += regexp.QuoteMeta(string(char))
+		}
+	}
+
+	return regexp.Compile(expression + "(/.*)?$")
+}
+
+// Matches reports whether the slash separated path relative to the context root is ignored.
+func (i Ignore) Matches(path string) bool {
+	ignored := false
+
+	for _, pattern := range i {
+		if pattern.pattern.MatchString(path) {
+			ignored = !pattern.exclusion
+		}
+	}
+
+	return ignored
+}
+
+func (i Ignore) hasExclusions() bool {
+	for _, pattern := range i {
+		if pattern.exclusion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Files returns regular files, directories and symlinks of the build context which are not ignored,
+// in a deterministic order.
+func (i Ignore) Files(dir string) ([]string, error) {
+	files := make([]string, 0)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(dir, path)
+		if err != nil || relative == "." {
+			return err
+		}
+
+		relative = filepath.ToSlash(relative)
+
+		if i.Matches(relative) {
+			// files below an ignored directory can only come back through an exclusion
+			if info.IsDir() && !i.hasExclusions() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		files = append(files, relative)
+
+		return nil
+	})
+
+	sort.Strings(files)
+
+	return files, err
+}
+
+// Archive streams the build context as a tarball. Extra files (e.g. a rendered Dockerfile)
+// are added at the root of the archive.
+func Archive(dir string, extra map[string][]byte) (io.ReadCloser, error) {
+	ignore, err := ReadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ignore.Files(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(writeArchive(writer, dir, files, extra))
+	}()
+
+	return reader, nil
+}
+
+func writeArchive(output io.Writer, dir string, files []string, extra map[string][]byte) error {
+	archive := tar.NewWriter(output)
+
+	for _, name := range files {
+		if err := addFile(archive, dir, name); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0)
+
+	for name := range extra {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(extra[name])),
+			ModTime: time.Unix(0, 0),
+		}
+
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if _, err := archive.Write(extra[name]); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func addFile(archive *tar.Writer, dir string, name string) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(archive, file)
+
+	return err
+}
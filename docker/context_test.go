@@ -0,0 +1,180 @@
+package docker
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestContext(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "gcp-builder-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestIgnoreMatches(t *testing.T) {
+	dir := newTestContext(t, map[string]string{
+		dockerIgnore: "# comment\n\nnode_modules\n/dist/\n**/*.log\n!src/keep.log\ntmp?\nconfig/*.yml\n\\!important\nbuild[0-9]\ncache[!a]\n",
+	})
+	defer os.RemoveAll(dir)
+
+	ignore, err := ReadIgnore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"node_modules":              true,
+		"node_modules/lib/index.js": true,
+		"src/node_modules":          false,
+		"dist/app.js":               true,
+		"distribution":              false,
+		"debug.log":                 true,
+		"src/nested/debug.log":      true,
+		"src/keep.log":              false,
+		"tmp1":                      true,
+		"tmp12":                     false,
+		"config/app.yml":            true,
+		"config/nested/app.yml":     false,
+		"!important":                true,
+		"main.go":                   false,
+		"build1":                    true,
+		"buildx":                    false,
+		"cacheb":                    true,
+		"cachea":                    false,
+		"cache/":                    false,
+	}
+
+	for path, expected := range tests {
+		if ignored := ignore.Matches(path); ignored != expected {
+			t.Errorf("%s: expected ignored %t, got %t", path, expected, ignored)
+		}
+	}
+}
+
+func TestReadIgnoreWithoutFile(t *testing.T) {
+	dir := newTestContext(t, map[string]string{"main.go": "package main"})
+	defer os.RemoveAll(dir)
+
+	ignore, err := ReadIgnore(dir)
+	if err != nil || len(ignore) != 0 || ignore.Matches("main.go") {
+		t.Errorf("expected nothing to be ignored, got %v (%v)", ignore, err)
+	}
+}
+
+func TestReadInvalidIgnore(t *testing.T) {
+	for _, pattern := range []string{"src/[a-", "src/[]", "src/[z-a]"} {
+		dir := newTestContext(t, map[string]string{dockerIgnore: pattern + "\n"})
+
+		if _, err := ReadIgnore(dir); err == nil || !strings.Contains(err.Error(), "InvalidIgnorePattern") {
+			t.Errorf("%s: expected InvalidIgnorePattern, got %v", pattern, err)
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	files := map[string]string{
+		"node_modules/lib/index.js": "skipped",
+		"src/main.go":               "package main",
+		"src/debug.log":             "skipped",
+		"src/keep.log":              "kept",
+		"Dockerfile":                "FROM scratch",
+	}
+
+	tests := []struct {
+		ignore   string
+		expected []string
+	}{
+		{"node_modules\n*.log\n", []string{".dockerignore", "Dockerfile", "src", "src/debug.log", "src/keep.log", "src/main.go"}},
+		{"node_modules\n**/*.log\n!src/keep.log\n", []string{".dockerignore", "Dockerfile", "src", "src/keep.log", "src/main.go"}},
+		{"*\n!src\n", []string{"src", "src/debug.log", "src/keep.log", "src/main.go"}},
+	}
+
+	for _, test := range tests {
+		files[dockerIgnore] = test.ignore
+		dir := newTestContext(t, files)
+
+		ignore, err := ReadIgnore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listed, err := ignore.Files(dir)
+		if err != nil || !reflect.DeepEqual(listed, test.expected) {
+			t.Errorf("%q: expected files %v, got %v (%v)", test.ignore, test.expected, listed, err)
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := newTestContext(t, map[string]string{
+		dockerIgnore:    "src/*.log\n",
+		"src/main.go":   "package main",
+		"src/debug.log": "skipped",
+	})
+	defer os.RemoveAll(dir)
+
+	if err := os.Symlink("main.go", filepath.Join(dir, "src", "link.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := Archive(dir, map[string][]byte{".gcp-builder.Dockerfile": []byte("FROM scratch")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reader.Close()
+
+	archive := tar.NewReader(reader)
+	contents := make(map[string]string)
+	names := make([]string, 0)
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(archive)
+		contents[header.Name] = string(body) + header.Linkname
+		names = append(names, header.Name)
+	}
+
+	expected := []string{dockerIgnore, "src/", "src/link.go", "src/main.go", ".gcp-builder.Dockerfile"}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected archive %v, got %v", expected, names)
+	}
+
+	if contents["src/main.go"] != "package main" || contents["src/link.go"] != "main.go" || contents[".gcp-builder.Dockerfile"] != "FROM scratch" {
+		t.Errorf("unexpected archive contents %v", contents)
+	}
+}
@@ -0,0 +1,223 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+type BuildOptions struct {
+	Tags        []string
+	Dockerfile  string
+	BuildArgs   map[string]string
+	Labels      map[string]string
+	Target      string
+	NetworkMode string
+	Platform    string
+	CacheFrom   []string
+	// Registries holds credentials used to pull base images
+	Registries map[string]AuthConfig
+}
+
+type Image struct {
+	Id          string      `json:"Id"`
+	RepoDigests []string    `json:"RepoDigests"`
+	Config      ImageConfig `json:"Config"`
+}
+
+// ImageConfig is the runtime configuration of an image.
+type ImageConfig struct {
+	User         string              `json:"User"`
+	Entrypoint   []string            `json:"Entrypoint"`
+	Cmd          []string            `json:"Cmd"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+}
+
+type buildAux struct {
+	Id string `json:"ID"`
+}
+
+type pushAux struct {
+	Tag    string `json:"Tag"`
+	Digest string `json:"Digest"`
+}
+
+// Build sends the build context tarball to the daemon, streams progress to output
+// and returns the id of the built image.
+func (c *Client) Build(context io.Reader, options BuildOptions, output io.Writer) (string, error) {
+	query := url.Values{}
+
+	for _, tag := range options.Tags {
+		query.Add("t", tag)
+	}
+
+	query.Set("dockerfile", options.Dockerfile)
+	query.Set("rm", "1")
+	query.Set("forcerm", "1")
+
+	for name, values := range map[string]map[string]string{"buildargs": options.BuildArgs, "labels": options.Labels} {
+		if len(values) == 0 {
+			continue
+		}
+
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return "", err
+		}
+
+		query.Set(name, string(encoded))
+	}
+
+	for name, value := range map[string]string{"target": options.Target, "networkmode": options.NetworkMode, "platform": options.Platform} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	if len(options.CacheFrom) > 0 {
+		cacheFrom, err := json.Marshal(options.CacheFrom)
+		if err != nil {
+			return "", err
+		}
+
+		query.Set("cachefrom", string(cacheFrom))
+	}
+
+	headers := map[string]string{"Content-Type": "application/x-tar"}
+
+	if len(options.Registries) > 0 {
+		registries, err := json.Marshal(options.Registries)
+		if err != nil {
+			return "", err
+		}
+
+		headers["X-Registry-Config"] = base64.URLEncoding.EncodeToString(registries)
+	}
+
+	c.logger.Printf("Building %s", strings.Join(options.Tags, ", "))
+
+	response, err := c.do("POST", "/build", query, headers, context)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	id := ""
+
+	err = readMessages(response.Body, output, func(raw json.RawMessage) error {
+		aux := buildAux{}
+
+		if err := json.Unmarshal(raw, &aux); err == nil && aux.Id != "" {
+			id = aux.Id
+		}
+
+		return nil
+	})
+
+	return id, err
+}
+
+// Tag adds the target reference to the source image.
+func (c *Client) Tag(source, target string) error {
+	repository, tag := splitTag(target)
+
+	query := url.Values{}
+	query.Set("repo", repository)
+	query.Set("tag", tag)
+
+	c.logger.Printf("Tagging %s as %s", source, target)
+
+	response, err := c.do("POST", fmt.Sprintf("/images/%s/tag", source), query, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// Pull streams pull progress to output.
+func (c *Client) Pull(reference string, auth AuthConfig, output io.Writer) error {
+	repository, tag := splitTag(reference)
+
+	encoded, err := auth.encode()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("fromImage", repository)
+	query.Set("tag", tag)
+
+	c.logger.Printf("Pulling %s", reference)
+
+	response, err := c.do("POST", "/images/create", query, map[string]string{"X-Registry-Auth": encoded}, nil)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	return readMessages(response.Body, output, nil)
+}
+
+// Push streams push progress to output and returns the digest reported by the registry.
+func (c *Client) Push(reference string, auth AuthConfig, output io.Writer) (string, error) {
+	repository, tag := splitTag(reference)
+
+	encoded, err := auth.encode()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("tag", tag)
+
+	c.logger.Printf("Pushing %s", reference)
+
+	response, err := c.do("POST", fmt.Sprintf("/images/%s/push", repository), query, map[string]string{"X-Registry-Auth": encoded}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	digest := ""
+
+	err = readMessages(response.Body, output, func(raw json.RawMessage) error {
+		aux := pushAux{}
+
+		if err := json.Unmarshal(raw, &aux); err == nil && aux.Digest != "" {
+			digest = aux.Digest
+		}
+
+		return nil
+	})
+
+	return digest, err
+}
+
+func (c *Client) Inspect(reference string) (Image, error) {
+	response, err := c.do("GET", fmt.Sprintf("/images/%s/json", reference), nil, nil, nil)
+	if err != nil {
+		return Image{}, err
+	}
+
+	defer response.Body.Close()
+
+	image := Image{}
+
+	return image, json.NewDecoder(response.Body).Decode(&image)
+}
+
+// splitTag splits repository:tag, the tag defaults to latest.
+func splitTag(reference string) (string, string) {
+	if colon := strings.LastIndex(reference, ":"); colon > strings.LastIndex(reference, "/") {
+		return reference[:colon], reference[colon+1:]
+	}
+
+	return reference, "latest"
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// message is a single JSON object of the progress stream returned by build and push.
+type message struct {
+	Stream      string          `json:"stream"`
+	Status      string          `json:"status"`
+	Progress    string          `json:"progress"`
+	Id          string          `json:"id"`
+	Error       string          `json:"error"`
+	ErrorDetail errorDetail     `json:"errorDetail"`
+	Aux         json.RawMessage `json:"aux"`
+}
+
+type errorDetail struct {
+	Message string `json:"message"`
+}
+
+// readMessages writes progress to output as it arrives and passes aux payloads to the callback.
+// Intermediate progress bars are skipped to keep CI logs readable.
+func readMessages(body io.Reader, output io.Writer, aux func(json.RawMessage) error) error {
+	decoder := json.NewDecoder(body)
+
+	for {
+		msg := message{}
+
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Error != "" || msg.ErrorDetail.Message != "" {
+			if msg.ErrorDetail.Message != "" {
+				return errors.New(msg.ErrorDetail.Message)
+			}
+
+			return errors.New(msg.Error)
+		}
+
+		switch {
+		case msg.Stream != "":
+			fmt.Fprint(output, msg.Stream)
+		case msg.Status != "" && msg.Progress == "" && msg.Id != "":
+			fmt.Fprintf(output, "%s: %s\n", msg.Id, msg.Status)
+		case msg.Status != "" && msg.Progress == "":
+			fmt.Fprintln(output, msg.Status)
+		}
+
+		if len(msg.Aux) > 0 && aux != nil {
+			if err := aux(msg.Aux); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 const installerLocation = "https://dl.google.com/dl/cloudsdk/release/install_google_cloud_sdk.bash"
@@ -116,17 +117,40 @@ func (i *Client) Install() error {
 }
 
 func (i *Client) CaptureCommand(command string, args []string) ([]byte, error) {
+	return i.CaptureCommandWithEnv(command, args, []string{})
+}
+
+// CaptureCommandWithEnv runs command with additional environment variables.
+func (i *Client) CaptureCommandWithEnv(command string, args []string, env []string) ([]byte, error) {
 	cmd := exec.Command(i.sdkBinaryLocation(command), args...)
 
 	i.log.Printf("Running command %s %+v", command, args)
 
-	cmd.Env = []string{
-		fmt.Sprintf("PATH=%s:%s", i.sdkBinaryLocation(""), os.Getenv("PATH")),
-		fmt.Sprintf("KUBECONFIG=%s/.kube", i.InstallDir()),
+	cmd.Env = append(i.environment(), env...)
+
+	out := bytes.Buffer{}
+
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return out.Bytes(), err
 	}
 
+	return out.Bytes(), nil
+}
+
+// CaptureCommandWithInput runs command with input passed on stdin. Input is never logged.
+func (i *Client) CaptureCommandWithInput(command string, args []string, input []byte) ([]byte, error) {
+	cmd := exec.Command(i.sdkBinaryLocation(command), args...)
+
+	i.log.Printf("Running command %s %+v with %d bytes of input", command, args, len(input))
+
+	cmd.Env = i.environment()
+
 	out := bytes.Buffer{}
 
+	cmd.Stdin = bytes.NewReader(input)
 	cmd.Stdout = &out
 	cmd.Stderr = &out
 
@@ -142,10 +166,7 @@ func (i *Client) RunCommand(command string, args []string) error {
 
 	i.log.Printf("Running command %s %+v", command, args)
 
-	cmd.Env = []string{
-		fmt.Sprintf("PATH=%s:%s", i.sdkBinaryLocation(""), os.Getenv("PATH")),
-		fmt.Sprintf("KUBECONFIG=%s/.kube", i.InstallDir()),
-	}
+	cmd.Env = i.environment()
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -157,9 +178,26 @@ func (i *Client) RunCommand(command string, args []string) error {
 	return nil
 }
 
+// environment passes PATH with SDK binaries, KUBECONFIG, HOME and DOCKER_* variables to commands.
+func (i *Client) environment() []string {
+	env := []string{
+		fmt.Sprintf("PATH=%s:%s", i.sdkBinaryLocation(""), os.Getenv("PATH")),
+		fmt.Sprintf("KUBECONFIG=%s/.kube", i.InstallDir()),
+		fmt.Sprintf("HOME=%s", os.Getenv("HOME")),
+	}
+
+	for _, variable := range os.Environ() {
+		if strings.HasPrefix(variable, "DOCKER_") {
+			env = append(env, variable)
+		}
+	}
+
+	return env
+}
+
 func (i *Client) sdkBinaryLocation(command string) string {
-	if command == "docker" {
-		return "docker"
+	if command == "docker" || command == "helm" || command == "executor" {
+		return command
 	}
 
 	return fmt.Sprintf("%s/google-cloud-sdk/bin/%s", i.InstallDir(), command)
@@ -0,0 +1,249 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const configMapHashLength = 10
+
+// ConfigMap holds UTF-8 files in Data and other files base64 encoded in BinaryData.
+type ConfigMap struct {
+	Name       string
+	Data       map[string]string
+	BinaryData map[string]string
+}
+
+type configMapDocument struct {
+	ApiVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   metadata          `yaml:"metadata"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	BinaryData map[string]string `yaml:"binaryData,omitempty"`
+}
+
+type metadata struct {
+	Name        string            `yaml:"name"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// ConfigMap returns the name of a ConfigMap generated from configFiles. The name carries
+// a hash of the contents so pods referencing it are rolled out whenever files change.
+func (c Context) ConfigMap(name string) (string, error) {
+	configMap, err := c.loadConfigMap(name)
+	if err != nil {
+		return "", err
+	}
+
+	return configMap.Name, nil
+}
+
+// ConfigMaps returns all ConfigMaps generated from configFiles of the current environment.
+func (c Context) ConfigMaps() ([]ConfigMap, error) {
+	configMaps := make([]ConfigMap, 0)
+
+	for _, file := range c.CurrentEnvironment.ConfigFiles {
+		configMap, err := c.loadConfigMap(file.Name)
+		if err != nil {
+			return configMaps, err
+		}
+
+		configMaps = append(configMaps, configMap)
+	}
+
+	return configMaps, nil
+}
+
+// ConfigMapsManifest renders generated ConfigMaps as Kubernetes documents.
+func (c Context) ConfigMapsManifest() (string, error) {
+	configMaps, err := c.ConfigMaps()
+	if err != nil {
+		return "", err
+	}
+
+	documents := make([]string, 0)
+
+	for _, configMap := range configMaps {
+		out, err := yaml.Marshal(configMapDocument{
+			ApiVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata: metadata{
+				Name:        configMap.Name,
+				Labels:      c.Labels(),
+				Annotations: c.Annotations(),
+			},
+			Data:       configMap.Data,
+			BinaryData: configMap.BinaryData,
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		documents = append(documents, fmt.Sprintf("# Source: configFiles/%s\n%s", configMap.Name, out))
+	}
+
+	return strings.Join(documents, "---\n"), nil
+}
+
+// AppendConfigMaps appends generated ConfigMaps to a rendered manifest.
+func (c Context) AppendConfigMaps(filename string) error {
+	manifest, err := c.ConfigMapsManifest()
+	if err != nil || manifest == "" {
+		return err
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if len(contents) > 0 && !bytes.HasSuffix(contents, []byte("\n")) {
+		contents = append(contents, '\n')
+	}
+
+	contents = append(contents, []byte("---\n"+manifest)...)
+
+	return ioutil.WriteFile(filename, contents, os.ModePerm)
+}
+
+func (c Context) loadConfigMap(name string) (ConfigMap, error) {
+	if configMap, exists := c.configMaps[name]; exists {
+		return configMap, nil
+	}
+
+	for _, file := range c.CurrentEnvironment.ConfigFiles {
+		if file.Name != name {
+			continue
+		}
+
+		contents, err := c.readConfigFiles(file)
+		if err != nil {
+			return ConfigMap{}, err
+		}
+
+		configMap := ConfigMap{
+			Name:       fmt.Sprintf("%s-%s", name, hashData(contents)),
+			Data:       make(map[string]string),
+			BinaryData: make(map[string]string),
+		}
+
+		for key, value := range contents {
+			if utf8.ValidString(value) {
+				configMap.Data[key] = value
+			} else {
+				configMap.BinaryData[key] = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+		}
+
+		c.configMaps[name] = configMap
+
+		return configMap, nil
+	}
+
+	return ConfigMap{}, errors.New(fmt.Sprintf("ConfigMapNotFound(%s)", name))
+}
+
+func (c Context) readConfigFiles(file project.ConfigFile) (map[string]string, error) {
+	data := make(map[string]string)
+
+	files, err := listFiles(file.Path)
+	if err != nil {
+		return data, err
+	}
+
+	for _, path := range files {
+		contents, err := c.readConfigFile(path, file.Template)
+		if err != nil {
+			return data, err
+		}
+
+		data[filepath.Base(path)] = contents
+	}
+
+	return data, nil
+}
+
+// readConfigFile returns the file contents, rendered when interpolation is enabled. Binary
+// files are never rendered.
+func (c Context) readConfigFile(path string, interpolate bool) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil || !interpolate || !utf8.Valid(contents) {
+		return string(contents), err
+	}
+
+	tmpl, err := c.newTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	if err := parseFile(tmpl, path); err != nil {
+		return "", err
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err := tmpl.ExecuteTemplate(buffer, path, c); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+func hashData(data map[string]string) string {
+	keys := make([]string, 0)
+
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	hash := sha256.New()
+
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s\x00%s\x00", key, data[key])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:configMapHashLength]
+}
+
+// listFiles returns the file itself or files directly inside a directory.
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,168 @@
+package kubernetes
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var testConfigFiles = map[string]string{
+	"config/app.properties": "environment={{ .EnvironmentName }}\nreplicas=1\n",
+	"config/logback.xml":    "<configuration/>",
+	"config/nested/skip":    "directories are not read recursively",
+	"single/app.yaml":       "name: {{ .Config.Project.Name }}",
+	"deployment.yml":        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  configMap: {{ .ConfigMap \"app-config\" }}\n",
+}
+
+func newConfigMapsContext(t *testing.T, files ...project.ConfigFile) *Context {
+	return newTestContext(t, &project.Environment{ConfigFiles: files})
+}
+
+func TestConfigMap(t *testing.T) {
+	defer inTemporaryDirectory(t, testConfigFiles)()
+
+	ctx := newConfigMapsContext(t,
+		project.ConfigFile{Name: "app-config", Path: "config", Template: true},
+		project.ConfigFile{Name: "raw-config", Path: "config"},
+		project.ConfigFile{Name: "single", Path: "single/app.yaml", Template: true},
+	)
+
+	configMaps, err := ctx.ConfigMaps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(configMaps) != 3 {
+		t.Fatalf("expected 3 ConfigMaps, got %+v", configMaps)
+	}
+
+	rendered, raw, single := configMaps[0], configMaps[1], configMaps[2]
+
+	if !strings.HasPrefix(rendered.Name, "app-config-") || len(rendered.Name) != len("app-config-")+configMapHashLength {
+		t.Errorf("expected name with content hash, got %s", rendered.Name)
+	}
+
+	if rendered.Data["app.properties"] != "environment=test\nreplicas=1\n" || rendered.Data["logback.xml"] != "<configuration/>" || len(rendered.Data) != 2 {
+		t.Errorf("expected rendered files of the directory, got %v", rendered.Data)
+	}
+
+	if raw.Data["app.properties"] != testConfigFiles["config/app.properties"] {
+		t.Errorf("expected files not to be rendered without template, got %q", raw.Data["app.properties"])
+	}
+
+	if single.Data["app.yaml"] != "name: app" {
+		t.Errorf("expected single rendered file, got %v", single.Data)
+	}
+
+	if name, err := ctx.ConfigMap("app-config"); err != nil || name != rendered.Name {
+		t.Errorf("expected name %s, got %s (%v)", rendered.Name, name, err)
+	}
+
+	if _, err := ctx.ConfigMap("missing"); err == nil || !strings.Contains(err.Error(), "ConfigMapNotFound") {
+		t.Errorf("expected ConfigMapNotFound, got %v", err)
+	}
+}
+
+func TestConfigMapNameChangesWithContents(t *testing.T) {
+	defer inTemporaryDirectory(t, testConfigFiles)()
+
+	file := project.ConfigFile{Name: "app-config", Path: "config", Template: true}
+
+	first, err := newConfigMapsContext(t, file).ConfigMap("app-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := newConfigMapsContext(t, file).ConfigMap("app-config"); again != first {
+		t.Errorf("expected stable name %s, got %s", first, again)
+	}
+
+	if err := ioutil.WriteFile("config/logback.xml", []byte("<configuration debug=\"true\"/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, _ := newConfigMapsContext(t, file).ConfigMap("app-config"); changed == first {
+		t.Errorf("expected name to change with contents, got %s", changed)
+	}
+}
+
+func TestBinaryConfigFiles(t *testing.T) {
+	defer inTemporaryDirectory(t, map[string]string{
+		"binary/keystore.jks": string([]byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x7b, 0x7b}),
+		"binary/app.conf":     "{{ .EnvironmentName }}",
+	})()
+
+	configMap, err := newConfigMapsContext(t, project.ConfigFile{Name: "binary", Path: "binary", Template: true}).loadConfigMap("binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.BinaryData["keystore.jks"] != "/u3+7QB7ew==" || configMap.Data["app.conf"] != "test" {
+		t.Errorf("expected binary file base64 encoded and text file rendered, got %v and %v", configMap.BinaryData, configMap.Data)
+	}
+
+	if _, exists := configMap.Data["keystore.jks"]; exists {
+		t.Error("expected binary file not to be stored in data")
+	}
+}
+
+func TestAppendConfigMaps(t *testing.T) {
+	defer inTemporaryDirectory(t, testConfigFiles)()
+
+	ctx := newConfigMapsContext(t, project.ConfigFile{Name: "app-config", Path: "config", Template: true})
+
+	if err := ctx.RenderManifests("deployment.yml", "out.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.AppendConfigMaps("out.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile("out.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, _ := ctx.ConfigMap("app-config")
+	documents := strings.Split(string(contents), "---\n")
+
+	if len(documents) != 2 || !strings.Contains(documents[0], "configMap: "+name) {
+		t.Fatalf("expected deployment referencing %s followed by the ConfigMap, got\n%s", name, contents)
+	}
+
+	if !strings.HasPrefix(documents[1], "# Source: configFiles/"+name+"\n") {
+		t.Errorf("expected source comment, got\n%s", documents[1])
+	}
+
+	configMap := configMapDocument{}
+
+	if err := yaml.Unmarshal([]byte(documents[1]), &configMap); err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.Kind != "ConfigMap" || configMap.Metadata.Name != name || configMap.Metadata.Labels["name"] != "app" ||
+		configMap.Metadata.Annotations[AnnotationCommit] != "0123456789abcdef" || configMap.Data["app.properties"] != "environment=test\nreplicas=1\n" {
+		t.Errorf("unexpected ConfigMap %+v", configMap)
+	}
+}
+
+func TestAppendWithoutConfigMaps(t *testing.T) {
+	defer inTemporaryDirectory(t, testConfigFiles)()
+
+	ctx := newConfigMapsContext(t)
+
+	if err := ioutil.WriteFile("out.yml", []byte("kind: A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.AppendConfigMaps("out.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if contents, _ := ioutil.ReadFile("out.yml"); string(contents) != "kind: A\n" {
+		t.Errorf("expected manifest unchanged, got %q", contents)
+	}
+}
@@ -0,0 +1,56 @@
+package kubernetes
+
+import (
+	"encoding/json"
+)
+
+const AnnotationProject = "gcp-builder/project"
+const AnnotationVersion = "gcp-builder/version"
+const AnnotationCommit = "gcp-builder/commit"
+
+type helmValues struct {
+	Build struct {
+		Commit string `json:"commit"`
+	} `json:"build"`
+}
+
+type deploymentList struct {
+	Items []struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// DeployedCommit finds the commit recorded in the annotations of deployments belonging to
+// the project in `kubectl get deployments -o json` output. Empty string is returned when
+// the project was never deployed with annotations.
+func DeployedCommit(output []byte, project string) (string, error) {
+	list := deploymentList{}
+
+	if err := json.Unmarshal(output, &list); err != nil {
+		return "", err
+	}
+
+	for _, item := range list.Items {
+		annotations := item.Metadata.Annotations
+
+		if annotations[AnnotationProject] == project && annotations[AnnotationCommit] != "" {
+			return annotations[AnnotationCommit], nil
+		}
+	}
+
+	return "", nil
+}
+
+// HelmDeployedCommit reads the commit from build values of a release in
+// `helm get values -o json` output.
+func HelmDeployedCommit(output []byte) (string, error) {
+	values := helmValues{}
+
+	if err := json.Unmarshal(output, &values); err != nil {
+		return "", err
+	}
+
+	return values.Build.Commit, nil
+}
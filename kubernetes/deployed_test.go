@@ -0,0 +1,60 @@
+package kubernetes
+
+import (
+	"testing"
+)
+
+func TestDeployedCommit(t *testing.T) {
+	output := []byte(`{"items": [
+  {"metadata": {"name": "legacy", "annotations": {"deployment.kubernetes.io/revision": "3"}}},
+  {"metadata": {"name": "other", "annotations": {"gcp-builder/project": "d-c-other", "gcp-builder/commit": "fedcba9"}}},
+  {"metadata": {"name": "app", "annotations": {"gcp-builder/project": "d-c-app", "gcp-builder/commit": "0123456"}}}
+]}`)
+
+	tests := []struct {
+		project  string
+		expected string
+	}{
+		{"d-c-app", "0123456"},
+		{"d-c-missing", ""},
+	}
+
+	for _, test := range tests {
+		commit, err := DeployedCommit(output, test.project)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.project, err)
+			continue
+		}
+
+		if commit != test.expected {
+			t.Errorf("%s: expected commit %q, got %q", test.project, test.expected, commit)
+		}
+	}
+
+	if _, err := DeployedCommit([]byte("No resources found."), "d-c-app"); err == nil {
+		t.Error("expected error for output which is not JSON")
+	}
+}
+
+func TestHelmDeployedCommit(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{`{"build": {"commit": "0123456", "version": "1.0.0"}, "replicas": 2}`, "0123456"},
+		{`{"replicas": 2}`, ""},
+		{`null`, ""},
+	}
+
+	for _, test := range tests {
+		commit, err := HelmDeployedCommit([]byte(test.output))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.output, err)
+			continue
+		}
+
+		if commit != test.expected {
+			t.Errorf("%s: expected commit %q, got %q", test.output, test.expected, commit)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"gopkg.in/yaml.v2"
+	"sort"
+)
+
+// labelDocument adds labels missing in metadata of a rendered resource and sets the given
+// annotations. Documents which are not resources are returned unchanged.
+func labelDocument(document string, labels map[string]string, annotations map[string]string) (string, error) {
+	resource := yaml.MapSlice{}
+
+	if err := yaml.Unmarshal([]byte(document), &resource); err != nil {
+		return "", err
+	}
+
+	metadata, ok := value(resource, "metadata").(yaml.MapSlice)
+	if !ok {
+		return document, nil
+	}
+
+	existing, _ := value(metadata, "labels").(yaml.MapSlice)
+
+	for _, key := range sortedKeys(labels) {
+		if value(existing, key) == nil {
+			existing = append(existing, yaml.MapItem{Key: key, Value: labels[key]})
+		}
+	}
+
+	existingAnnotations, _ := value(metadata, "annotations").(yaml.MapSlice)
+
+	for _, key := range sortedKeys(annotations) {
+		existingAnnotations = set(existingAnnotations, key, annotations[key])
+	}
+
+	if len(existing) > 0 {
+		metadata = set(metadata, "labels", existing)
+	}
+
+	if len(existingAnnotations) > 0 {
+		metadata = set(metadata, "annotations", existingAnnotations)
+	}
+
+	out, err := yaml.Marshal(set(resource, "metadata", metadata))
+
+	return string(out), err
+}
+
+func value(mapping yaml.MapSlice, key string) interface{} {
+	for _, item := range mapping {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+
+	return nil
+}
+
+func set(mapping yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for index, item := range mapping {
+		if item.Key == key {
+			mapping[index].Value = value
+			return mapping
+		}
+	}
+
+	return append(mapping, yaml.MapItem{Key: key, Value: value})
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0)
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,50 @@
+package kubernetes
+
+import (
+	"testing"
+)
+
+func TestLabelDocument(t *testing.T) {
+	labels := map[string]string{"name": "app", "environment": "test"}
+	annotations := map[string]string{AnnotationCommit: "0123456", AnnotationProject: "d-c-app"}
+
+	tests := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{
+			"resource without labels",
+			"apiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  type: NodePort",
+			"apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n  labels:\n    environment: test\n    name: app\n" +
+				"  annotations:\n    gcp-builder/commit: \"0123456\"\n    gcp-builder/project: d-c-app\nspec:\n  type: NodePort\n",
+		},
+		{
+			"labels set by the template win, annotations are replaced",
+			"kind: Deployment\nmetadata:\n  name: app\n  labels:\n    name: other\n  annotations:\n    gcp-builder/commit: stale\n    team: core",
+			"kind: Deployment\nmetadata:\n  name: app\n  labels:\n    name: other\n    environment: test\n" +
+				"  annotations:\n    gcp-builder/commit: \"0123456\"\n    team: core\n    gcp-builder/project: d-c-app\n",
+		},
+		{
+			"document which is not a resource",
+			"kind: A\nname: app",
+			"kind: A\nname: app",
+		},
+	}
+
+	for _, test := range tests {
+		labelled, err := labelDocument(test.document, labels, annotations)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if labelled != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.expected, labelled)
+		}
+	}
+
+	if _, err := labelDocument("metadata: [", labels, annotations); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
@@ -1,6 +1,10 @@
 package kubernetes
 
-import "github.com/wendigo/gcp-builder/project"
+import (
+	"github.com/wendigo/gcp-builder/platforms"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/registry"
+)
 
 import (
 	"bytes"
@@ -10,18 +14,31 @@ import (
 	"log"
 	"os"
 	"strings"
-	"text/template"
 )
 
 type Context struct {
 	Config             *project.Configuration
 	Env                string
-	Version            string
+	Version            project.Version
 	CurrentEnvironment *project.Environment
 	ContainersShas     map[string]string
+	Commit             platforms.Commit
+	Build              Build
+	DeployedCommit     string
+	Changelog          []platforms.Commit
+	configMaps         map[string]ConfigMap
 }
 
-func NewContext(prj *project.Configuration, environment string, version string) (*Context, error) {
+type Build struct {
+	Platform   string
+	Branch     string
+	Tag        string
+	Number     string
+	Url        string
+	Repository string
+}
+
+func NewContext(prj *project.Configuration, environment string, version project.Version) (*Context, error) {
 
 	var currentEnvironment *project.Environment = nil
 
@@ -41,6 +58,8 @@ func NewContext(prj *project.Configuration, environment string, version string)
 		Version:            version,
 		CurrentEnvironment: currentEnvironment,
 		ContainersShas:     make(map[string]string),
+		Changelog:          make([]platforms.Commit, 0),
+		configMaps:         make(map[string]ConfigMap),
 	}, nil
 }
 
@@ -48,6 +67,10 @@ func (c Context) Environment() *project.Environment {
 	return c.CurrentEnvironment
 }
 
+func (c Context) Images() []project.Image {
+	return c.Config.ImagesFor(c.CurrentEnvironment)
+}
+
 func (c Context) EnvironmentName() string {
 	return c.Env
 }
@@ -64,25 +87,88 @@ func (c Context) Variable(name string) string {
 	return fmt.Sprintf("VariableNotFound(%s)", name)
 }
 
-func (c Context) Container(name string) string {
-	path := fmt.Sprintf("%s/%s/%s:%s", c.CurrentEnvironment.Cloud.Registry, c.Config.Project.FullName(), name, c.Version)
+func (c Context) Major() uint64 {
+	return c.Version.Major
+}
+
+func (c Context) Minor() uint64 {
+	return c.Version.Minor
+}
 
-	if project.IsSnapshotVersion(c.Version) {
+func (c Context) Patch() uint64 {
+	return c.Version.Patch
+}
 
-		if id, exists := c.ContainersShas[path]; exists {
-			return fmt.Sprintf("%s/%s/%s@%s", c.CurrentEnvironment.Cloud.Registry, c.Config.Project.FullName(), name, id)
-		}
+func (c Context) Prerelease() string {
+	return c.Version.Prerelease
+}
+
+// Container returns the image reference pinned to its digest (of the manifest list for
+// multi-platform images) when it is known, the tag otherwise.
+func (c Context) Container(name string) string {
+	path := c.ContainerPath(name)
+
+	if id, exists := c.ContainersShas[path]; exists {
+		return fmt.Sprintf("%s/%s/%s@%s", c.Registry(name), c.Config.Project.FullName(), name, id)
 	}
 
 	return path
 }
 
 func (c Context) ContainerPath(name string) string {
-	return fmt.Sprintf("%s/%s/%s:%s", c.CurrentEnvironment.Cloud.Registry, c.Config.Project.FullName(), name, c.Version)
+	return c.ContainerVersion(name, c.Version.Tag())
 }
 
 func (c Context) ContainerVersion(name string, version string) string {
-	return fmt.Sprintf("%s/%s/%s:%s", c.CurrentEnvironment.Cloud.Registry, c.Config.Project.FullName(), name, version)
+	return fmt.Sprintf("%s/%s/%s:%s", c.Registry(name), c.Config.Project.FullName(), name, project.SanitizeTag(version))
+}
+
+// Registry returns the registry url the image is pushed to, urls without a host point to Docker Hub.
+func (c Context) Registry(name string) string {
+	for _, image := range c.Images() {
+		if image.Name == name {
+			return registry.Qualify(c.CurrentEnvironment.RegistryFor(image).Url)
+		}
+	}
+
+	return registry.Qualify(c.CurrentEnvironment.Cloud.Registry)
+}
+
+func (c Context) Labels() map[string]string {
+	return map[string]string{
+		"domain":      c.Config.Project.Domain,
+		"context":     c.Config.Project.Context,
+		"name":        c.Config.Project.Name,
+		"environment": c.EnvironmentName(),
+	}
+}
+
+// Selector selects resources of the project by its labels.
+func (c Context) Selector() string {
+	return fmt.Sprintf("domain=%s,context=%s,name=%s", c.Config.Project.Domain, c.Config.Project.Context, c.Config.Project.Name)
+}
+
+// Namespace returns the namespace the project is deployed to, empty for the default one.
+// Kustomize overlays without a configured namespace are deployed to their own namespace.
+func (c Context) Namespace() string {
+	kubernetes := c.CurrentEnvironment.Kubernetes
+
+	switch {
+	case kubernetes.Helm != nil && kubernetes.Helm.Namespace != "":
+		return kubernetes.Helm.Namespace
+	case kubernetes.Namespace == "" && kubernetes.Kustomize != nil:
+		return overlayNamespace(kubernetes.Kustomize.Path)
+	}
+
+	return kubernetes.Namespace
+}
+
+func (c Context) Annotations() map[string]string {
+	return map[string]string{
+		AnnotationProject: c.Config.Project.FullName(),
+		AnnotationVersion: c.Version.String(),
+		AnnotationCommit:  c.Commit.Hash,
+	}
 }
 
 func (c Context) EnvVariable(key string) string {
@@ -97,19 +183,18 @@ func (c Context) EnvVariable(key string) string {
 
 func (ctx *Context) InterpolateConfig(input string, output string) error {
 
-	inputTemplate, err := ioutil.ReadFile(input)
+	tmpl, err := ctx.newTemplate()
 	if err != nil {
 		return err
 	}
 
-	tmpl, err := template.New(ctx.Env).Parse(string(inputTemplate))
-	if err != nil {
+	if err := parseFile(tmpl, input); err != nil {
 		return err
 	}
 
 	buffer := &bytes.Buffer{}
 
-	if err := tmpl.Execute(buffer, ctx); err != nil {
+	if err := tmpl.ExecuteTemplate(buffer, input, ctx); err != nil {
 		return err
 	}
 
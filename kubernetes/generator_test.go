@@ -0,0 +1,37 @@
+package kubernetes
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	release, err := project.ParseVersion("1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version  project.Version
+		digest   string
+		expected string
+	}{
+		{release, "sha256:index", "eu.gcr.io/project/d-c-app/api@sha256:index"},
+		{release, "", "eu.gcr.io/project/d-c-app/api:1.0.0"},
+		{project.NewSnapshotVersion("master", "master-snapshot"), "sha256:index", "eu.gcr.io/project/d-c-app/api@sha256:index"},
+		{project.NewSnapshotVersion("master", "master-snapshot"), "", "eu.gcr.io/project/d-c-app/api:master-snapshot"},
+	}
+
+	for _, test := range tests {
+		ctx := newTestContext(t, &project.Environment{Cloud: project.GoogleCloud{Registry: "eu.gcr.io/project"}})
+		ctx.Version = test.version
+
+		if test.digest != "" {
+			ctx.ContainersShas[ctx.ContainerPath("api")] = test.digest
+		}
+
+		if container := ctx.Container("api"); container != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.version, test.expected, container)
+		}
+	}
+}
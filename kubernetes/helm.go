@@ -0,0 +1,95 @@
+package kubernetes
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// HelmRelease returns the release name, project full name unless configured.
+func (c Context) HelmRelease() string {
+	if helm := c.CurrentEnvironment.Kubernetes.Helm; helm != nil && helm.Release != "" {
+		return helm.Release
+	}
+
+	return c.Config.Project.FullName()
+}
+
+// HelmValues generates chart values from variables, image references and build metadata.
+// Variables with dotted names (e.g. image.pullPolicy) are nested.
+func (c Context) HelmValues() (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{})
+
+	for _, variables := range []project.Variables{c.Config.Variables, c.CurrentEnvironment.Kubernetes.Variables} {
+		for _, variable := range variables {
+			setValue(values, strings.Split(variable.Name, "."), variable.Value)
+		}
+	}
+
+	images := make(map[interface{}]interface{})
+
+	for _, image := range c.Images() {
+		images[image.Name] = map[string]string{
+			"reference":  c.Container(image.Name),
+			"repository": strings.TrimSuffix(c.ContainerPath(image.Name), ":"+c.Version.Tag()),
+			"tag":        c.Version.Tag(),
+			"digest":     c.ContainersShas[c.ContainerPath(image.Name)],
+		}
+	}
+
+	configMaps := make(map[interface{}]interface{})
+
+	for _, file := range c.CurrentEnvironment.ConfigFiles {
+		name, err := c.ConfigMap(file.Name)
+		if err != nil {
+			return values, err
+		}
+
+		configMaps[file.Name] = name
+	}
+
+	values["images"] = images
+	values["configMaps"] = configMaps
+	values["build"] = map[string]string{
+		"project":     c.Config.Project.FullName(),
+		"environment": c.CurrentEnvironment.Name,
+		"version":     c.Version.String(),
+		"commit":      c.Commit.Hash,
+	}
+
+	return values, nil
+}
+
+func (c Context) WriteHelmValues(output string) error {
+	values, err := c.HelmValues()
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Generating helm values '%s' for environment '%s'", output, c.CurrentEnvironment.Name)
+
+	return ioutil.WriteFile(output, out, os.ModePerm)
+}
+
+func setValue(values map[interface{}]interface{}, path []string, value string) {
+	if len(path) == 1 {
+		values[path[0]] = value
+		return
+	}
+
+	nested, ok := values[path[0]].(map[interface{}]interface{})
+	if !ok {
+		nested = make(map[interface{}]interface{})
+		values[path[0]] = nested
+	}
+
+	setValue(nested, path[1:], value)
+}
@@ -0,0 +1,138 @@
+package kubernetes
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"testing"
+)
+
+func newHelmContext(t *testing.T) *Context {
+	version, err := project.ParseVersion("1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	environment := &project.Environment{
+		Name:  "test",
+		Cloud: project.GoogleCloud{Registry: "eu.gcr.io/project"},
+		Kubernetes: project.Kubernetes{
+			Helm: &project.Helm{Chart: "charts/app"},
+			Variables: project.Variables{
+				{Name: "replicas", Value: "3"},
+				{Name: "image.pullPolicy", Value: "Always"},
+				{Name: "ingress.tls.enabled", Value: "true"},
+			},
+		},
+		Images:     []project.Image{{Name: "worker", Registry: "hub"}},
+		Registries: []project.Registry{{Name: "hub", Url: "team"}},
+	}
+
+	configuration := &project.Configuration{
+		Project:      project.Project{Name: "app", Domain: "d", Context: "c"},
+		Environments: []*project.Environment{environment},
+		Variables:    project.Variables{{Name: "replicas", Value: "1"}, {Name: "ingress.host", Value: "app.example.com"}},
+		Images:       []project.Image{{Name: "api"}, {Name: "worker"}},
+	}
+
+	ctx, err := NewContext(configuration, "test", version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.Commit.Hash = "0123456789abcdef"
+
+	return ctx
+}
+
+func TestHelmValues(t *testing.T) {
+	ctx := newHelmContext(t)
+	ctx.ContainersShas["eu.gcr.io/project/d-c-app/api:1.0.0"] = "sha256:api"
+
+	values, err := ctx.HelmValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `build:
+  commit: 0123456789abcdef
+  environment: test
+  project: d-c-app
+  version: 1.0.0
+configMaps: {}
+image:
+  pullPolicy: Always
+images:
+  api:
+    digest: sha256:api
+    reference: eu.gcr.io/project/d-c-app/api@sha256:api
+    repository: eu.gcr.io/project/d-c-app/api
+    tag: 1.0.0
+  worker:
+    digest: ""
+    reference: docker.io/team/d-c-app/worker:1.0.0
+    repository: docker.io/team/d-c-app/worker
+    tag: 1.0.0
+ingress:
+  host: app.example.com
+  tls:
+    enabled: "true"
+replicas: "3"
+`
+
+	if string(out) != expected {
+		t.Errorf("expected values\n%s\ngot\n%s", expected, out)
+	}
+}
+
+func TestWriteHelmValues(t *testing.T) {
+	defer inTemporaryDirectory(t, map[string]string{"config/app.properties": "env={{ .EnvironmentName }}"})()
+
+	ctx := newHelmContext(t)
+	ctx.CurrentEnvironment.ConfigFiles = []project.ConfigFile{{Name: "app-config", Path: "config", Template: true}}
+
+	if err := ctx.WriteHelmValues("values.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile("values.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := struct {
+		ConfigMaps map[string]string `yaml:"configMaps"`
+	}{}
+
+	if err := yaml.Unmarshal(contents, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := ctx.ConfigMap("app-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values.ConfigMaps["app-config"] != name {
+		t.Errorf("expected ConfigMap %s in values, got %v", name, values.ConfigMaps)
+	}
+}
+
+func TestHelmRelease(t *testing.T) {
+	ctx := newHelmContext(t)
+
+	if release := ctx.HelmRelease(); release != "d-c-app" {
+		t.Errorf("expected project full name as release, got %s", release)
+	}
+
+	ctx.CurrentEnvironment.Kubernetes.Helm.Release = "app-test"
+
+	if release := ctx.HelmRelease(); release != "app-test" {
+		t.Errorf("expected configured release, got %s", release)
+	}
+}
@@ -0,0 +1,179 @@
+package kubernetes
+
+import (
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const configFilesDirectory = "configFiles"
+
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+type kustomization struct {
+	Namespace          string                   `yaml:"namespace,omitempty"`
+	Resources          []string                 `yaml:"resources"`
+	Labels             []kustomizationLabel     `yaml:"labels"`
+	CommonAnnotations  map[string]string        `yaml:"commonAnnotations"`
+	Images             []kustomizationImage     `yaml:"images"`
+	ConfigMapGenerator []kustomizationGenerator `yaml:"configMapGenerator,omitempty"`
+}
+
+// kustomizationLabel adds labels to metadata only, as selectors of existing Deployments
+// are immutable.
+type kustomizationLabel struct {
+	Pairs            map[string]string `yaml:"pairs"`
+	IncludeSelectors bool              `yaml:"includeSelectors"`
+}
+
+// kustomizationGenerator generates a ConfigMap with a content hash suffix, references
+// to its name in the overlay are updated by kustomize.
+type kustomizationGenerator struct {
+	Name  string   `yaml:"name"`
+	Files []string `yaml:"files"`
+}
+
+type kustomizationImage struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// WriteKustomization creates a kustomization in dir which builds the configured overlay
+// with images pinned to pushed containers, project labels and build annotations. Images
+// are matched by their name in project.yml. Config files are generated as ConfigMaps and
+// secrets are added as placeholders without data so they end up in the namespace of the
+// overlay.
+func (c Context) WriteKustomization(dir string) error {
+	overlay, err := relativePath(dir, c.CurrentEnvironment.Kubernetes.Kustomize.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	generators, err := c.writeConfigFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := c.writeSecretPlaceholders(dir)
+	if err != nil {
+		return err
+	}
+
+	generated := kustomization{
+		Namespace:          c.Namespace(),
+		Resources:          []string{overlay},
+		Labels:             []kustomizationLabel{{Pairs: c.Labels()}},
+		CommonAnnotations:  c.Annotations(),
+		Images:             make([]kustomizationImage, 0),
+		ConfigMapGenerator: generators,
+	}
+
+	if secrets != "" {
+		generated.Resources = append(generated.Resources, secrets)
+	}
+
+	for _, image := range c.Images() {
+		path := c.ContainerPath(image.Name)
+
+		kustomized := kustomizationImage{
+			Name:    image.Name,
+			NewName: strings.TrimSuffix(path, ":"+c.Version.Tag()),
+		}
+
+		if digest, exists := c.ContainersShas[path]; exists {
+			kustomized.Digest = digest
+		} else {
+			kustomized.NewTag = c.Version.Tag()
+		}
+
+		generated.Images = append(generated.Images, kustomized)
+	}
+
+	out, err := yaml.Marshal(generated)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Generating kustomization in '%s' from overlay '%s' for environment '%s'",
+		dir,
+		c.CurrentEnvironment.Kubernetes.Kustomize.Path,
+		c.CurrentEnvironment.Name,
+	)
+
+	return ioutil.WriteFile(filepath.Join(dir, "kustomization.yaml"), out, os.ModePerm)
+}
+
+// writeConfigFiles writes config files of the environment to dir for ConfigMap generators.
+func (c Context) writeConfigFiles(dir string) ([]kustomizationGenerator, error) {
+	generators := make([]kustomizationGenerator, 0)
+
+	for _, file := range c.CurrentEnvironment.ConfigFiles {
+		contents, err := c.readConfigFiles(file)
+		if err != nil {
+			return generators, err
+		}
+
+		if err := os.MkdirAll(filepath.Join(dir, configFilesDirectory, file.Name), os.ModePerm); err != nil {
+			return generators, err
+		}
+
+		generator := kustomizationGenerator{Name: file.Name, Files: make([]string, 0)}
+
+		for _, key := range sortedKeys(contents) {
+			path := filepath.Join(configFilesDirectory, file.Name, key)
+
+			if err := ioutil.WriteFile(filepath.Join(dir, path), []byte(contents[key]), 0644); err != nil {
+				return generators, err
+			}
+
+			generator.Files = append(generator.Files, path)
+		}
+
+		generators = append(generators, generator)
+	}
+
+	return generators, nil
+}
+
+// overlayNamespace reads the namespace set by the kustomization of the overlay.
+func overlayNamespace(path string) string {
+	for _, name := range kustomizationFiles {
+		contents, err := ioutil.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			continue
+		}
+
+		overlay := kustomization{}
+
+		if err := yaml.Unmarshal(contents, &overlay); err != nil {
+			return ""
+		}
+
+		return overlay.Namespace
+	}
+
+	return ""
+}
+
+func relativePath(from string, to string) (string, error) {
+	absoluteFrom, err := filepath.Abs(from)
+	if err != nil {
+		return "", err
+	}
+
+	absoluteTo, err := filepath.Abs(to)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(absoluteFrom, absoluteTo)
+}
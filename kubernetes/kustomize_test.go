@@ -0,0 +1,132 @@
+package kubernetes
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKustomization(t *testing.T) {
+	defer inTemporaryDirectory(t, map[string]string{"overlays/test/kustomization.yaml": "resources: [../../base]"})()
+
+	ctx := newTestContext(t, &project.Environment{
+		Cloud:      project.GoogleCloud{Registry: "eu.gcr.io/project"},
+		Kubernetes: project.Kubernetes{Kustomize: &project.Kustomize{Path: "overlays/test"}},
+		Images:     []project.Image{{Name: "worker", Registry: "hub"}},
+		Registries: []project.Registry{{Name: "hub", Url: "team"}},
+	})
+
+	ctx.Config.Images = append(ctx.Config.Images, project.Image{Name: "worker"})
+	ctx.ContainersShas["eu.gcr.io/project/d-c-app/api:master-snapshot"] = "sha256:api"
+
+	if err := ctx.WriteKustomization(filepath.Join("generated", "test")); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join("generated", "test", "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated := kustomization{}
+
+	if err := yaml.Unmarshal(contents, &generated); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(generated.Resources) == 0 || generated.Resources[0] != filepath.Join("..", "..", "overlays", "test") {
+		t.Errorf("expected overlay relative to the kustomization, got %v", generated.Resources)
+	}
+
+	if len(generated.Labels) != 1 || generated.Labels[0].IncludeSelectors || generated.Labels[0].Pairs["name"] != "app" || generated.Labels[0].Pairs["environment"] != "test" {
+		t.Errorf("expected project labels without selectors, got %+v", generated.Labels)
+	}
+
+	if generated.CommonAnnotations[AnnotationCommit] != "0123456789abcdef" || generated.CommonAnnotations[AnnotationProject] != "d-c-app" {
+		t.Errorf("expected build annotations, got %v", generated.CommonAnnotations)
+	}
+
+	expected := []kustomizationImage{
+		{Name: "api", NewName: "eu.gcr.io/project/d-c-app/api", Digest: "sha256:api"},
+		{Name: "worker", NewName: "docker.io/team/d-c-app/worker", NewTag: "master-snapshot"},
+	}
+
+	if len(generated.Images) != len(expected) {
+		t.Fatalf("expected images %+v, got %+v", expected, generated.Images)
+	}
+
+	for index := range expected {
+		if generated.Images[index] != expected[index] {
+			t.Errorf("expected image %+v, got %+v", expected[index], generated.Images[index])
+		}
+	}
+}
+
+func TestWriteKustomizationConfigMaps(t *testing.T) {
+	defer inTemporaryDirectory(t, map[string]string{
+		"overlays/test/kustomization.yaml": "namespace: overlay\nresources: [../../base]",
+		"config/app.properties":            "environment={{ .EnvironmentName }}",
+	})()
+
+	ctx := newTestContext(t, &project.Environment{
+		Kubernetes:  project.Kubernetes{Kustomize: &project.Kustomize{Path: "overlays/test"}},
+		ConfigFiles: []project.ConfigFile{{Name: "app-config", Path: "config", Template: true}},
+	})
+
+	dir := filepath.Join("generated", "test")
+
+	if err := ctx.WriteKustomization(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated := kustomization{}
+
+	if err := yaml.Unmarshal(contents, &generated); err != nil {
+		t.Fatal(err)
+	}
+
+	if generated.Namespace != "overlay" {
+		t.Errorf("expected namespace of the overlay, got %q", generated.Namespace)
+	}
+
+	file := filepath.Join(configFilesDirectory, "app-config", "app.properties")
+
+	if len(generated.ConfigMapGenerator) != 1 || generated.ConfigMapGenerator[0].Name != "app-config" ||
+		len(generated.ConfigMapGenerator[0].Files) != 1 || generated.ConfigMapGenerator[0].Files[0] != file {
+		t.Fatalf("expected ConfigMap generator for app-config, got %+v", generated.ConfigMapGenerator)
+	}
+
+	if rendered, _ := ioutil.ReadFile(filepath.Join(dir, file)); string(rendered) != "environment=test" {
+		t.Errorf("expected rendered config file, got %q", rendered)
+	}
+}
+
+func TestKustomizeNamespace(t *testing.T) {
+	defer inTemporaryDirectory(t, map[string]string{
+		"overlays/test/kustomization.yaml": "namespace: overlay",
+		"overlays/bare/kustomization.yml":  "resources: [../../base]",
+	})()
+
+	tests := []struct {
+		kubernetes project.Kubernetes
+		expected   string
+	}{
+		{project.Kubernetes{Kustomize: &project.Kustomize{Path: "overlays/test"}}, "overlay"},
+		{project.Kubernetes{Namespace: "configured", Kustomize: &project.Kustomize{Path: "overlays/test"}}, "configured"},
+		{project.Kubernetes{Kustomize: &project.Kustomize{Path: "overlays/bare"}}, ""},
+		{project.Kubernetes{Kustomize: &project.Kustomize{Path: "overlays/missing"}}, ""},
+	}
+
+	for _, test := range tests {
+		if namespace := newTestContext(t, &project.Environment{Kubernetes: test.kubernetes}).Namespace(); namespace != test.expected {
+			t.Errorf("%s: expected namespace %q, got %q", test.kubernetes.Kustomize.Path, test.expected, namespace)
+		}
+	}
+}
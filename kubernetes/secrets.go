@@ -0,0 +1,216 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/secrets"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const AnnotationSecret = "gcp-builder/secret"
+
+const defaultSecretType = "Opaque"
+
+const secretsFile = "secrets.yaml"
+
+var encryptedExtensions = []string{".age", ".gpg", ".pgp", ".asc"}
+
+type secretDocument struct {
+	ApiVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   metadata          `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+// SecretsManifest decrypts secrets of the current environment and renders them as
+// Kubernetes Secret documents. The result must only be kept in memory.
+func (c Context) SecretsManifest() (string, error) {
+	documents := make([]string, 0)
+
+	for _, secret := range c.CurrentEnvironment.Secrets {
+		data, err := c.decryptSecret(secret)
+		if err != nil {
+			return "", err
+		}
+
+		out, err := yaml.Marshal(c.newSecretDocument(secret, metadata{
+			Name:        secret.Name,
+			Labels:      c.Labels(),
+			Annotations: c.Annotations(),
+		}, data))
+
+		if err != nil {
+			return "", err
+		}
+
+		documents = append(documents, fmt.Sprintf("# Source: secrets/%s\n%s", secret.Name, out))
+	}
+
+	return strings.Join(documents, "---\n"), nil
+}
+
+// FillSecrets decrypts data of Secret placeholders of a built kustomization. The result
+// must only be kept in memory.
+func (c Context) FillSecrets(manifest []byte) ([]byte, error) {
+	documents := make([]string, 0)
+
+	for _, document := range splitDocuments(string(manifest)) {
+		filled, err := c.fillSecret(document)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, strings.TrimSuffix(filled, "\n")+"\n")
+	}
+
+	return []byte(strings.Join(documents, "---\n")), nil
+}
+
+func (c Context) fillSecret(document string) (string, error) {
+	resource := yaml.MapSlice{}
+
+	if err := yaml.Unmarshal([]byte(document), &resource); err != nil {
+		return "", err
+	}
+
+	metadata, _ := value(resource, "metadata").(yaml.MapSlice)
+	annotations, _ := value(metadata, "annotations").(yaml.MapSlice)
+	name, _ := value(annotations, AnnotationSecret).(string)
+
+	if value(resource, "kind") != "Secret" || name == "" {
+		return document, nil
+	}
+
+	for _, secret := range c.CurrentEnvironment.Secrets {
+		if secret.Name != name {
+			continue
+		}
+
+		data, err := c.decryptSecret(secret)
+		if err != nil {
+			return "", err
+		}
+
+		filled := yaml.MapSlice{}
+
+		for _, key := range sortedKeys(data) {
+			filled = append(filled, yaml.MapItem{Key: key, Value: data[key]})
+		}
+
+		out, err := yaml.Marshal(set(resource, "data", filled))
+
+		return string(out), err
+	}
+
+	return "", errors.New(fmt.Sprintf("SecretNotFound(%s)", name))
+}
+
+// writeSecretPlaceholders writes Secrets without data to dir so kustomize applies the
+// namespace, name prefix and labels of the overlay to them. Returns an empty resource
+// when there are no secrets.
+func (c Context) writeSecretPlaceholders(dir string) (string, error) {
+	documents := make([]string, 0)
+
+	for _, secret := range c.CurrentEnvironment.Secrets {
+		out, err := yaml.Marshal(c.newSecretDocument(secret, metadata{
+			Name:        secret.Name,
+			Annotations: map[string]string{AnnotationSecret: secret.Name},
+		}, map[string]string{}))
+
+		if err != nil {
+			return "", err
+		}
+
+		documents = append(documents, string(out))
+	}
+
+	if len(documents) == 0 {
+		return "", nil
+	}
+
+	return secretsFile, ioutil.WriteFile(filepath.Join(dir, secretsFile), []byte(strings.Join(documents, "---\n")), 0644)
+}
+
+func (c Context) newSecretDocument(secret project.Secret, meta metadata, data map[string]string) secretDocument {
+	secretType := secret.Type
+	if secretType == "" {
+		secretType = defaultSecretType
+	}
+
+	return secretDocument{
+		ApiVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   meta,
+		Type:       secretType,
+		Data:       data,
+	}
+}
+
+// secretKeys reads decryption keys from SECRETS_AGE_KEY, SECRETS_PGP_KEY (base64 encoded
+// armored private key) and SECRETS_PGP_PASSPHRASE, suffixed with the environment name first.
+func (c Context) secretKeys() (secrets.Keys, error) {
+	keys := secrets.Keys{
+		AgeIdentities: c.EnvVariable("SECRETS_AGE_KEY"),
+		PgpPassphrase: c.EnvVariable("SECRETS_PGP_PASSPHRASE"),
+	}
+
+	if encoded := c.EnvVariable("SECRETS_PGP_KEY"); encoded != "" {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return keys, errors.New("InvalidPgpKeyEncoding(SECRETS_PGP_KEY)")
+		}
+
+		keys.PgpPrivateKey = string(decoded)
+	}
+
+	return keys, nil
+}
+
+func (c Context) decryptSecret(secret project.Secret) (map[string]string, error) {
+	data := make(map[string]string)
+
+	keys, err := c.secretKeys()
+	if err != nil {
+		return data, err
+	}
+
+	files, err := listFiles(secret.Path)
+	if err != nil {
+		return data, err
+	}
+
+	for _, file := range files {
+		ciphertext, err := ioutil.ReadFile(file)
+		if err != nil {
+			return data, err
+		}
+
+		plaintext, err := secrets.Decrypt(ciphertext, keys)
+		if err != nil {
+			return data, errors.New(fmt.Sprintf("SecretDecryptionFailed(%s): %s", file, err))
+		}
+
+		data[secretKey(file)] = base64.StdEncoding.EncodeToString(plaintext)
+	}
+
+	return data, nil
+}
+
+// secretKey strips encryption extension from the file name: password.txt.age -> password.txt
+func secretKey(file string) string {
+	name := filepath.Base(file)
+
+	for _, extension := range encryptedExtensions {
+		if strings.HasSuffix(name, extension) {
+			return strings.TrimSuffix(name, extension)
+		}
+	}
+
+	return name
+}
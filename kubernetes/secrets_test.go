@@ -0,0 +1,219 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withSecretKeys generates age and PGP keys, exports them for the test environment and
+// returns encrypted files.
+func withSecretKeys(t *testing.T) (map[string]string, func()) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ageFile := &bytes.Buffer{}
+
+	ageWriter, err := age.Encrypt(ageFile, identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ageWriter.Write([]byte("sql-password"))
+
+	if err := ageWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entity, err := openpgp.NewEntity("gcp-builder", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := &bytes.Buffer{}
+	keyWriter, err := pgparmor.Encode(key, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entity.SerializePrivateWithoutSigning(keyWriter, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	keyWriter.Close()
+
+	pgpFile := &bytes.Buffer{}
+
+	pgpWriter, err := openpgp.Encrypt(pgpFile, []*openpgp.Entity{entity}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgpWriter.Write([]byte("sql-user"))
+
+	if err := pgpWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SECRETS_AGE_KEY_TEST", identity.String())
+	os.Setenv("SECRETS_PGP_KEY_TEST", base64.StdEncoding.EncodeToString(key.Bytes()))
+
+	files := map[string]string{
+		"secrets/sql/password.age":         ageFile.String(),
+		"secrets/sql/user.gpg":             pgpFile.String(),
+		"overlays/test/kustomization.yaml": "resources: [../../base]",
+	}
+
+	return files, func() {
+		os.Unsetenv("SECRETS_AGE_KEY_TEST")
+		os.Unsetenv("SECRETS_PGP_KEY_TEST")
+	}
+}
+
+func newSecretsContext(t *testing.T) *Context {
+	return newTestContext(t, &project.Environment{
+		Kubernetes: project.Kubernetes{Kustomize: &project.Kustomize{Path: "overlays/test"}},
+		Secrets:    []project.Secret{{Name: "sql-credentials", Path: "secrets/sql"}},
+	})
+}
+
+func TestSecretsManifest(t *testing.T) {
+	files, unset := withSecretKeys(t)
+	defer unset()
+	defer inTemporaryDirectory(t, files)()
+
+	manifest, err := newSecretsContext(t).SecretsManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(manifest, "# Source: secrets/sql-credentials\n") {
+		t.Errorf("expected source comment, got\n%s", manifest)
+	}
+
+	secret := secretDocument{}
+
+	if err := yaml.Unmarshal([]byte(manifest), &secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if secret.Kind != "Secret" || secret.Type != "Opaque" || secret.Metadata.Name != "sql-credentials" || secret.Metadata.Labels["name"] != "app" {
+		t.Errorf("unexpected Secret %+v", secret)
+	}
+
+	expected := map[string]string{
+		"password": base64.StdEncoding.EncodeToString([]byte("sql-password")),
+		"user":     base64.StdEncoding.EncodeToString([]byte("sql-user")),
+	}
+
+	if len(secret.Data) != len(expected) || secret.Data["password"] != expected["password"] || secret.Data["user"] != expected["user"] {
+		t.Errorf("expected data %v, got %v", expected, secret.Data)
+	}
+}
+
+func TestSecretsManifestWithoutKeys(t *testing.T) {
+	files, unset := withSecretKeys(t)
+	unset()
+	defer inTemporaryDirectory(t, files)()
+
+	_, err := newSecretsContext(t).SecretsManifest()
+
+	if err == nil || !strings.Contains(err.Error(), "SecretDecryptionFailed") {
+		t.Errorf("expected SecretDecryptionFailed, got %v", err)
+	}
+}
+
+func TestKustomizationSecrets(t *testing.T) {
+	files, unset := withSecretKeys(t)
+	defer unset()
+	defer inTemporaryDirectory(t, files)()
+
+	ctx := newSecretsContext(t)
+	dir := filepath.Join("generated", "test")
+
+	if err := ctx.WriteKustomization(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated := kustomization{}
+
+	if err := yaml.Unmarshal(contents, &generated); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(generated.Resources) != 2 || generated.Resources[1] != secretsFile {
+		t.Fatalf("expected Secret placeholders in resources, got %v", generated.Resources)
+	}
+
+	placeholders, err := ioutil.ReadFile(filepath.Join(dir, secretsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(placeholders), base64.StdEncoding.EncodeToString([]byte("sql-password"))) {
+		t.Fatalf("expected placeholders without decrypted data, got\n%s", placeholders)
+	}
+
+	// kubectl kustomize output with namespace, name prefix and labels of the overlay
+	built := strings.Replace(string(placeholders), "  name: sql-credentials", "  name: prod-sql-credentials\n  namespace: overlay", 1)
+	built = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: prod-config\n---\n" + built
+
+	filled, err := ctx.FillSecrets([]byte(built))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := strings.Split(string(filled), "---\n")
+
+	if len(documents) != 2 || documents[0] != "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: prod-config\n" {
+		t.Fatalf("expected other documents unchanged, got\n%s", filled)
+	}
+
+	secret := secretDocument{}
+
+	if err := yaml.Unmarshal([]byte(documents[1]), &secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if secret.Metadata.Name != "prod-sql-credentials" || secret.Data["password"] != base64.StdEncoding.EncodeToString([]byte("sql-password")) ||
+		secret.Data["user"] != base64.StdEncoding.EncodeToString([]byte("sql-user")) {
+		t.Errorf("expected filled Secret, got %+v", secret)
+	}
+
+	unknown := strings.Replace(built, AnnotationSecret+": sql-credentials", AnnotationSecret+": other", 1)
+
+	if _, err := ctx.FillSecrets([]byte(unknown)); err == nil || !strings.Contains(err.Error(), "SecretNotFound(other)") {
+		t.Errorf("expected SecretNotFound, got %v", err)
+	}
+}
+
+func TestSecretKey(t *testing.T) {
+	tests := map[string]string{
+		"secrets/password.txt.age": "password.txt",
+		"secrets/token.gpg":        "token",
+		"secrets/key.asc":          "key",
+		"secrets/plain":            "plain",
+	}
+
+	for file, expected := range tests {
+		if key := secretKey(file); key != expected {
+			t.Errorf("%s: expected key %s, got %s", file, expected, key)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/templates"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Templates shared by every template of the project, e.g. {{ define "labels" }}...{{ end }}
+const helpersDirectory = "_helpers"
+
+// Files starting with this prefix only define partials and are not rendered on their own.
+const partialPrefix = "_"
+
+var templateExtensions = []string{".yml", ".yaml", ".tpl"}
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// RenderManifests renders a single template, every template in a directory or every
+// template matching a glob into one manifest. All templates and project helpers are
+// parsed together so partials defined in one file can be used in another. Rendered
+// resources get project labels and build annotations.
+func (ctx *Context) RenderManifests(pattern string, output string) error {
+	files, err := resolveTemplates(pattern)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := ctx.newTemplate()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := parseFile(tmpl, file); err != nil {
+			return err
+		}
+	}
+
+	documents := make([]string, 0)
+
+	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), partialPrefix) {
+			continue
+		}
+
+		buffer := &bytes.Buffer{}
+
+		if err := tmpl.ExecuteTemplate(buffer, file, ctx); err != nil {
+			return err
+		}
+
+		for _, document := range splitDocuments(buffer.String()) {
+			labelled, err := labelDocument(document, ctx.Labels(), ctx.Annotations())
+			if err != nil {
+				return errors.New(fmt.Sprintf("InvalidManifest(%s): %s", file, err))
+			}
+
+			documents = append(documents, fmt.Sprintf("# Source: %s\n%s\n", file, strings.TrimSpace(labelled)))
+		}
+	}
+
+	log.Printf("Generating '%s' from templates %v for environment '%s'",
+		output,
+		files,
+		ctx.CurrentEnvironment.Name,
+	)
+
+	return ioutil.WriteFile(output, []byte(strings.Join(documents, "---\n")), os.ModePerm)
+}
+
+// Render renders an inline template, e.g. a build argument, with the context.
+func (ctx *Context) Render(text string) (string, error) {
+	tmpl, err := ctx.newTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmpl.New("inline").Parse(text); err != nil {
+		return "", err
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err := tmpl.ExecuteTemplate(buffer, "inline", ctx); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+func (ctx *Context) newTemplate() (*template.Template, error) {
+	tmpl := template.New(ctx.Env).Funcs(templates.Functions())
+
+	helpers, err := filepath.Glob(filepath.Join(helpersDirectory, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(helpers)
+
+	for _, helper := range helpers {
+		if info, err := os.Stat(helper); err != nil || info.IsDir() {
+			continue
+		}
+
+		if err := parseFile(tmpl, helper); err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl, nil
+}
+
+func parseFile(tmpl *template.Template, filename string) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpl.New(filename).Parse(string(contents))
+	return err
+}
+
+// resolveTemplates returns the template file, templates in the directory or files matching
+// the glob. Directories matched by the glob are expanded.
+func resolveTemplates(pattern string) ([]string, error) {
+	matches := []string{pattern}
+
+	if _, err := os.Stat(pattern); err != nil {
+		if matches, err = filepath.Glob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	files := make([]string, 0)
+
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, match)
+			continue
+		}
+
+		templates, err := templatesIn(match)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, templates...)
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New(fmt.Sprintf("TemplatesNotFound(%s)", pattern))
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func templatesIn(directory string) ([]string, error) {
+	files := make([]string, 0)
+
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && isTemplate(path) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+func isTemplate(filename string) bool {
+	for _, extension := range templateExtensions {
+		if strings.HasSuffix(filename, extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitDocuments splits rendered YAML into non empty documents.
+func splitDocuments(rendered string) []string {
+	documents := make([]string, 0)
+
+	for _, document := range documentSeparator.Split(rendered, -1) {
+		if document = strings.TrimSpace(document); document != "" {
+			documents = append(documents, document)
+		}
+	}
+
+	return documents
+}
@@ -0,0 +1,134 @@
+package kubernetes
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inTemporaryDirectory runs the test in a temporary working directory with the given files.
+func inTemporaryDirectory(t *testing.T, files map[string]string) func() {
+	dir, err := ioutil.TempDir("", "gcp-builder-kubernetes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+}
+
+func newTestContext(t *testing.T, environment *project.Environment) *Context {
+	environment.Name = "test"
+
+	configuration := &project.Configuration{
+		Project:      project.Project{Name: "app", Domain: "d", Context: "c"},
+		Environments: []*project.Environment{environment},
+		Images:       []project.Image{{Name: "api"}},
+	}
+
+	ctx, err := NewContext(configuration, "test", project.NewSnapshotVersion("master", "master-snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.Commit.Hash = "0123456789abcdef"
+
+	return ctx
+}
+
+var testTemplates = map[string]string{
+	"_helpers/labels.tpl": `{{ define "labels" }}app: {{ .Config.Project.Name }}{{ end }}`,
+	"k8s/_partials.yml":   `{{ define "name" }}{{ .Config.Project.FullName }}{{ end }}`,
+	"k8s/a.yml":           "kind: A\nname: {{ template \"name\" . }}\n---\nkind: B\n{{ template \"labels\" . }}\n",
+	"k8s/nested/b.yaml":   "---\nkind: C\n",
+	"k8s/README.md":       "not a template",
+	"empty/README.md":     "no templates here",
+	"service.yml":         "apiVersion: v1\nkind: Service\nmetadata:\n  name: {{ .Config.Project.FullName }}\n",
+}
+
+func TestRenderManifests(t *testing.T) {
+	defer inTemporaryDirectory(t, testTemplates)()
+
+	ctx := newTestContext(t, &project.Environment{})
+	all := "# Source: k8s/a.yml\nkind: A\nname: d-c-app\n---\n# Source: k8s/a.yml\nkind: B\napp: app\n---\n# Source: k8s/nested/b.yaml\nkind: C\n"
+
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"k8s", all},
+		{"k8s/", all},
+		{"k*", all},
+		{"k8s/*.yml", "# Source: k8s/a.yml\nkind: A\nname: d-c-app\n---\n# Source: k8s/a.yml\nkind: B\napp: app\n"},
+		{"k8s/nested/b.yaml", "# Source: k8s/nested/b.yaml\nkind: C\n"},
+	}
+
+	for _, test := range tests {
+		if err := ctx.RenderManifests(test.pattern, "out.yml"); err != nil {
+			t.Errorf("%s: unexpected error %s", test.pattern, err)
+			continue
+		}
+
+		rendered, _ := ioutil.ReadFile("out.yml")
+
+		if string(rendered) != test.expected {
+			t.Errorf("%s: expected manifest\n%s\ngot\n%s", test.pattern, test.expected, rendered)
+		}
+	}
+}
+
+func TestRenderManifestsLabelsResources(t *testing.T) {
+	defer inTemporaryDirectory(t, testTemplates)()
+
+	ctx := newTestContext(t, &project.Environment{})
+
+	if err := ctx.RenderManifests("service.yml", "out.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, _ := ioutil.ReadFile("out.yml")
+
+	for _, expected := range []string{"# Source: service.yml\n", "  name: d-c-app\n", "    domain: d\n", "    environment: test\n", "    gcp-builder/commit: 0123456789abcdef\n"} {
+		if !strings.Contains(string(rendered), expected) {
+			t.Errorf("expected %q in manifest\n%s", expected, rendered)
+		}
+	}
+}
+
+func TestRenderManifestsWithoutTemplates(t *testing.T) {
+	defer inTemporaryDirectory(t, testTemplates)()
+
+	ctx := newTestContext(t, &project.Environment{})
+
+	for _, pattern := range []string{"empty", "missing/*.yml", "e*"} {
+		err := ctx.RenderManifests(pattern, "out.yml")
+
+		if err == nil || !strings.Contains(err.Error(), "TemplatesNotFound") {
+			t.Errorf("%s: expected TemplatesNotFound, got %v", pattern, err)
+		}
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"github.com/wendigo/gcp-builder/context"
 	"github.com/wendigo/gcp-builder/notifications/slack"
 	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
 )
 
-func Get(params context.Params) NotificationsProvider {
+func Get(params context.Params, templates map[string]string) NotificationsProvider {
 	for _, provider := range []NotificationsProvider{
-		slack.NewSlackProvider(params),
+		slack.NewSlackProvider(params, templates),
 		DiscardingProvider{},
 	} {
 		if provider != nil && provider.IsConfigured() {
@@ -24,6 +25,10 @@ type NotificationsProvider interface {
 	OnReleaseCompleted([]string, error)
 	OnImageBuilding(project.Image)
 	OnImageBuilt(project.Image, string, error)
+	OnImageCache(project.Image, report.Cache)
+	OnImageReused(project.Image, string)
+	OnImageTested(project.Image, []report.Test, error)
+	OnImagesScanned([]report.Scan, error)
 	OnImagePushing(project.Image)
 	OnImagePushed(project.Image, string, error)
 	OnConfigurationValidated(error)
@@ -35,15 +40,19 @@ type NotificationsProvider interface {
 type DiscardingProvider struct {
 }
 
-func (d DiscardingProvider) OnReleaseStarted([]string)                  {}
-func (d DiscardingProvider) OnReleaseCompleted([]string, error)         {}
-func (d DiscardingProvider) OnImageBuilding(project.Image)              {}
-func (d DiscardingProvider) OnImageBuilt(project.Image, string, error)  {}
-func (d DiscardingProvider) OnImagePushing(project.Image)               {}
-func (d DiscardingProvider) OnImagePushed(project.Image, string, error) {}
-func (d DiscardingProvider) OnConfigurationValidated(error)             {}
-func (d DiscardingProvider) OnDeploying()                               {}
-func (d DiscardingProvider) OnDeployed(string, error)                   {}
+func (d DiscardingProvider) OnReleaseStarted([]string)                         {}
+func (d DiscardingProvider) OnReleaseCompleted([]string, error)                {}
+func (d DiscardingProvider) OnImageBuilding(project.Image)                     {}
+func (d DiscardingProvider) OnImageBuilt(project.Image, string, error)         {}
+func (d DiscardingProvider) OnImageCache(project.Image, report.Cache)          {}
+func (d DiscardingProvider) OnImageReused(project.Image, string)               {}
+func (d DiscardingProvider) OnImageTested(project.Image, []report.Test, error) {}
+func (d DiscardingProvider) OnImagesScanned([]report.Scan, error)              {}
+func (d DiscardingProvider) OnImagePushing(project.Image)                      {}
+func (d DiscardingProvider) OnImagePushed(project.Image, string, error)        {}
+func (d DiscardingProvider) OnConfigurationValidated(error)                    {}
+func (d DiscardingProvider) OnDeploying()                                      {}
+func (d DiscardingProvider) OnDeployed(string, error)                          {}
 func (d DiscardingProvider) IsConfigured() bool {
 	return true
 }
@@ -1,70 +1,88 @@
 package slack
 
 import (
-	"fmt"
 	"github.com/wendigo/gcp-builder/context"
+	"github.com/wendigo/gcp-builder/platforms"
 )
 
-func errorAttachment(err error) []slackAttachment {
+func (s *NotificationProvider) errorAttachment() []slackAttachment {
 	return []slackAttachment{{
-		header:  "Error details",
-		content: err.Error(),
+		header:  s.template(TemplateErrorHeader),
+		content: s.template(TemplateErrorAttachment),
 		color:   colorError,
 	}}
 }
 
-func buildAttachment(ctx context.Params) []slackAttachment {
-	template := `Build platform: *{{ .BuildPlatform }}*
-Build url: {{ .BuildUrl }}
-Repository: {{ .BuildRepository }}
-Commit: *{{ .BuildCommit }}*
-Branch: *{{if .BuildBranch }}{{ .BuildBranch }}{{else}}n/a{{end}}*
-Tag: *{{if .BuildTag }}{{ .BuildTag }}{{else}}n/a{{end}}*
-Version: *{{ .ProjectVersion }}*
-Environment: *{{ .Environment }}*`
-
+func (s *NotificationProvider) buildAttachment() []slackAttachment {
 	return []slackAttachment{{
 		header:  "",
-		content: ctx.ExpandTemplate(template),
+		content: s.template(TemplateBuildAttachment),
 		color:   colorInfo,
 	}}
 }
 
-func outputAttachment(output string) []slackAttachment {
+func (s *NotificationProvider) outputAttachment() []slackAttachment {
 	return []slackAttachment{{
 		header:  "",
-		content: fmt.Sprintf("```%s```", output),
+		content: s.template(TemplateOutputAttachment),
 		color:   colorOK,
 	}}
 }
 
-func errorOutputAttachment(output string, err error) []slackAttachment {
-	return append(outputAttachment(output), errorAttachment(err)...)
+func (s *NotificationProvider) errorOutputAttachment() []slackAttachment {
+	return append(s.outputAttachment(), s.errorAttachment()...)
 }
 
-func projectAttachment(ctx context.Params) []slackAttachment {
-	template := `Version: *{{ .ProjectVersion }}*
-Environment: *{{ .Environment }}*
-Cluster: *{{ .KubernetesCluster }}*
-Zone: *{{ .KubernetesZone }}*
-Project: *{{ .CloudProject }}*
-Registry: {{ .CloudRegistry }}
-`
-
+func (s *NotificationProvider) projectAttachment() []slackAttachment {
 	return []slackAttachment{{
 		header:  "",
-		content: ctx.ExpandTemplate(template),
+		content: s.template(TemplateProjectAttachment),
 		color:   colorInfo,
 	}}
 }
 
-func imageAttachment(ctx context.Params) []slackAttachment {
-	template := `Container name: *{{ .ImageName }}*
-Registry: {{ .CloudRegistry }}/{{ .ProjectFullName}}/{{ .ImageName }}:{{ .BuildVersion }}`
+func (s *NotificationProvider) changelogAttachment(ctx context.Params) []slackAttachment {
+	if changelog, ok := ctx["Changelog"].([]platforms.Commit); !ok || len(changelog) == 0 {
+		return emptyAttachments
+	}
+
+	return []slackAttachment{{
+		header:  s.template(TemplateChangelogHeader),
+		content: s.template(TemplateChangelogAttachment),
+		color:   colorInfo,
+	}}
+}
 
+func (s *NotificationProvider) imageAttachment() []slackAttachment {
 	return []slackAttachment{{
 		header:  "",
-		content: ctx.ExpandTemplate(template),
+		content: s.template(TemplateImageAttachment),
 		color:   colorInfo,
 	}}
 }
+
+func (s *NotificationProvider) testsAttachment(passed bool) []slackAttachment {
+	color := colorOK
+	if !passed {
+		color = colorError
+	}
+
+	return []slackAttachment{{
+		header:  "",
+		content: s.template(TemplateTestsAttachment),
+		color:   color,
+	}}
+}
+
+func (s *NotificationProvider) scanAttachment(passed bool) []slackAttachment {
+	color := colorOK
+	if !passed {
+		color = colorError
+	}
+
+	return []slackAttachment{{
+		header:  "",
+		content: s.template(TemplateScanAttachment),
+		color:   color,
+	}}
+}
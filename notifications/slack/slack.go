@@ -4,6 +4,7 @@ import (
 	"github.com/nlopes/slack"
 	"github.com/wendigo/gcp-builder/context"
 	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
 	"log"
 	"os"
 	"strings"
@@ -22,6 +23,7 @@ type NotificationProvider struct {
 	botName         string
 	logger          *log.Logger
 	params          context.Params
+	templates       map[string]string
 	threadTimestamp string
 }
 
@@ -39,146 +41,168 @@ func envOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-func NewSlackProvider(params context.Params) *NotificationProvider {
+func NewSlackProvider(params context.Params, templates map[string]string) *NotificationProvider {
 	if token, exists := os.LookupEnv("SLACK_TOKEN"); exists {
-		return &NotificationProvider{
+		provider := &NotificationProvider{
 			client:    slack.New(token),
 			channelId: envOrDefault("SLACK_CHANNEL_ID", "release"),
 			botName:   envOrDefault("SLACK_BOT_NAME", "gcp-builder"),
 			logger: log.New(
 				os.Stdout, "[slack] ", log.Lmicroseconds,
 			),
-			params: params,
+			params:    params,
+			templates: templates,
 		}
+
+		for name := range templates {
+			if _, exists := DefaultTemplates[name]; !exists {
+				provider.logger.Printf("Unknown notification template %s will not be used", name)
+			}
+		}
+
+		return provider
 	}
 
 	return nil
 }
 
-func (s *NotificationProvider) OnReleaseStarted(steps []string) {
-	merged := s.params.Merge(context.Params{"Steps": strings.Join(steps, ", ")})
+func (s *NotificationProvider) template(name string) string {
+	if tpl, exists := s.templates[name]; exists {
+		return tpl
+	}
 
+	return DefaultTemplates[name]
+}
+
+func (s *NotificationProvider) OnReleaseStarted(steps []string) {
 	s.send(
-		merged.ExpandTemplate(":rocket: *{{ .ProjectFullName }}* is being run with steps `{{ .Steps }}` on *{{ .Environment }}* with version `{{ .ProjectVersion }}` :see_no_evil:"),
-		buildAttachment(s.params),
-		emptyParams,
+		TemplateReleaseStarted,
+		s.buildAttachment(),
+		context.Params{"Steps": strings.Join(steps, ", ")},
 	)
 }
 
 func (s *NotificationProvider) OnReleaseCompleted(steps []string, err error) {
-	merged := s.params.Merge(context.Params{"Steps": strings.Join(steps, ", ")})
+	params := context.Params{"Steps": strings.Join(steps, ", ")}
 
 	if err == nil {
-		s.send(
-			merged.ExpandTemplate("`{{ .Steps }}` ended *successfully* :heart:"),
-			emptyAttachments,
-			emptyParams,
-		)
+		s.send(TemplateReleaseSucceeded, emptyAttachments, params)
 	} else {
-		s.send(
-			merged.ExpandTemplate("{{ .Steps }} has *failed* :cry:"),
-			errorAttachment(err),
-			emptyParams,
-		)
+		s.send(TemplateReleaseFailed, s.errorAttachment(), params.Merge(errorParams(err)))
 	}
 }
 
 func (s *NotificationProvider) OnImageBuilding(image project.Image) {
-	merged := s.params.Merge(context.FromImage(image))
-
-	s.send(
-		merged.ExpandTemplate("Container *{{ .ImageName }}* is being built..."),
-		imageAttachment(merged),
-		emptyParams,
-	)
+	s.send(TemplateImageBuilding, s.imageAttachment(), context.FromImage(image))
 }
 
 func (s *NotificationProvider) OnImageBuilt(image project.Image, output string, err error) {
-	merged := s.params.Merge(context.FromImage(image))
+	params := context.FromImage(image).Merge(context.Params{"Output": output})
 
 	if err != nil {
-		s.send(
-			merged.ExpandTemplate("Container *{{ .ImageName }}* failed to build :cry:"),
-			errorOutputAttachment(output, err),
-			emptyParams,
-		)
+		s.send(TemplateImageBuildFailed, s.errorOutputAttachment(), params.Merge(errorParams(err)))
 	} else {
-		s.send(
-			merged.ExpandTemplate("Container *{{ .ImageName }}* was built successfully :grin:"),
-			outputAttachment(output),
-			emptyParams,
-		)
+		s.send(TemplateImageBuilt, s.outputAttachment(), params)
 	}
 }
 
-func (s *NotificationProvider) OnImagePushing(image project.Image) {
-	merged := s.params.Merge(context.FromImage(image))
+func (s *NotificationProvider) OnImageCache(image project.Image, cache report.Cache) {
+	s.send(TemplateImageCache, emptyAttachments, context.FromImage(image).Merge(context.Params{
+		"CacheHits":   cache.Hits,
+		"CacheSteps":  cache.Steps,
+		"CacheFrom":   strings.Join(cache.From, ", "),
+		"CacheExport": cache.Export,
+	}))
+}
 
-	s.send(
-		merged.ExpandTemplate("Container {{ .ImageName }} is being pushed... :boat:"),
-		emptyAttachments,
-		emptyParams,
-	)
+func (s *NotificationProvider) OnImageReused(image project.Image, reused string) {
+	s.send(TemplateImageReused, s.imageAttachment(), context.FromImage(image).Merge(context.Params{"ReusedImage": reused}))
+}
+
+func (s *NotificationProvider) OnImageTested(image project.Image, tests []report.Test, err error) {
+	passed := 0
+
+	for _, test := range tests {
+		if test.Passed {
+			passed++
+		}
+	}
+
+	params := context.FromImage(image).Merge(context.Params{
+		"Tests":       tests,
+		"TestsPassed": passed,
+		"TestsTotal":  len(tests),
+	})
+
+	if err != nil {
+		s.send(TemplateImageTestFailed, append(s.testsAttachment(false), s.errorAttachment()...), params.Merge(errorParams(err)))
+	} else if passed < len(tests) {
+		s.send(TemplateImageTestFailed, s.testsAttachment(false), params)
+	} else {
+		s.send(TemplateImageTested, s.testsAttachment(true), params)
+	}
+}
+
+func (s *NotificationProvider) OnImagesScanned(scans []report.Scan, err error) {
+	blocking := 0
+
+	for _, scan := range scans {
+		blocking += scan.Blocking
+	}
+
+	params := context.Params{"Scans": scans, "ScanBlocking": blocking}
+
+	if err != nil {
+		s.send(TemplateImagesScanFailed, append(s.scanAttachment(false), s.errorAttachment()...), params.Merge(errorParams(err)))
+	} else {
+		s.send(TemplateImagesScanned, s.scanAttachment(true), params)
+	}
+}
+
+func (s *NotificationProvider) OnImagePushing(image project.Image) {
+	s.send(TemplateImagePushing, emptyAttachments, context.FromImage(image))
 }
 
 func (s *NotificationProvider) OnImagePushed(image project.Image, output string, err error) {
-	merged := s.params.Merge(context.FromImage(image))
+	params := context.FromImage(image).Merge(context.Params{"Output": output})
 
 	if err != nil {
-		s.send(
-			merged.ExpandTemplate("Container *{{ .ImageName }}* failed to push to registry :cry:"),
-			errorOutputAttachment(output, err),
-			emptyParams,
-		)
+		s.send(TemplateImagePushFailed, s.errorOutputAttachment(), params.Merge(errorParams(err)))
 	} else {
-		s.send(
-			merged.ExpandTemplate("Container *{{ .ImageName }}* was successfully pushed to registry :grin:"),
-			outputAttachment(output),
-			emptyParams,
-		)
+		s.send(TemplateImagePushed, s.outputAttachment(), params)
 	}
 }
 
 func (s *NotificationProvider) OnConfigurationValidated(err error) {
 	if err != nil {
-		s.send(
-			s.params.ExpandTemplate("Kubernetes deployment configuration is invalid :cry:"),
-			errorAttachment(err),
-			emptyParams,
-		)
+		s.send(TemplateConfigurationInvalid, s.errorAttachment(), errorParams(err))
 	} else {
-		s.send(
-			s.params.ExpandTemplate("Kubernetes deployment configuration is valid :small_airplane:"),
-			emptyAttachments,
-			emptyParams,
-		)
+		s.send(TemplateConfigurationValid, emptyAttachments, emptyParams)
 	}
 }
 
 func (s *NotificationProvider) OnDeploying() {
 	s.send(
-		s.params.ExpandTemplate("Deploying to *{{ .Environment }}* cluster *{{ .KubernetesCluster }}*... :rocket:"),
-		projectAttachment(s.params),
+		TemplateDeploying,
+		append(s.projectAttachment(), s.changelogAttachment(s.params)...),
 		emptyParams,
 	)
 }
 
 func (s *NotificationProvider) OnDeployed(output string, err error) {
+	params := context.Params{"Output": output}
+
 	if err != nil {
-		s.send(
-			s.params.ExpandTemplate("Failed to deploy to *{{ .Environment }}* :tired_face:"),
-			errorOutputAttachment(output, err),
-			emptyParams,
-		)
+		s.send(TemplateDeployFailed, s.errorOutputAttachment(), params.Merge(errorParams(err)))
 	} else {
-		s.send(
-			s.params.ExpandTemplate("Deployed successfully to *{{ .Environment }}* :trophy:"),
-			outputAttachment(output),
-			emptyParams,
-		)
+		s.send(TemplateDeployed, s.outputAttachment(), params)
 	}
 }
 
+func errorParams(err error) context.Params {
+	return context.Params{"Error": err.Error()}
+}
+
 func (s *NotificationProvider) IsConfigured() bool {
 	return s.channelId != ""
 }
@@ -189,7 +213,7 @@ func (s *NotificationProvider) sendNotification(message string, attachments []sl
 		AsUser:          false,
 		IconURL:         "https://avatars1.githubusercontent.com/u/13629408?s=200&v=4",
 		ThreadTimestamp: s.threadTimestamp,
-		Markdown: true,
+		Markdown:        true,
 	}
 
 	parameters.Attachments = attachments
@@ -205,8 +229,8 @@ func (s *NotificationProvider) sendNotification(message string, attachments []sl
 	return err
 }
 
-func (s *NotificationProvider) send(msg string, attachments []slackAttachment, params context.Params) {
-	merged := params.Merge(s.params)
+func (s *NotificationProvider) send(template string, attachments []slackAttachment, params context.Params) {
+	merged := s.params.Merge(params)
 
 	slackAttachments := make([]slack.Attachment, 0)
 
@@ -219,7 +243,7 @@ func (s *NotificationProvider) send(msg string, attachments []slackAttachment, p
 		})
 	}
 
-	if err := s.sendNotification(merged.ExpandTemplate(msg), slackAttachments); err != nil {
-		s.logger.Printf("Could not send slack notification: %v", msg)
+	if err := s.sendNotification(merged.ExpandTemplate(s.template(template)), slackAttachments); err != nil {
+		s.logger.Printf("Could not send slack notification: %v", template)
 	}
 }
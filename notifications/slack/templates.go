@@ -0,0 +1,88 @@
+package slack
+
+const (
+	TemplateReleaseStarted       = "releaseStarted"
+	TemplateReleaseSucceeded     = "releaseSucceeded"
+	TemplateReleaseFailed        = "releaseFailed"
+	TemplateImageBuilding        = "imageBuilding"
+	TemplateImageBuilt           = "imageBuilt"
+	TemplateImageBuildFailed     = "imageBuildFailed"
+	TemplateImageCache           = "imageCache"
+	TemplateImageReused          = "imageReused"
+	TemplateImageTested          = "imageTested"
+	TemplateImageTestFailed      = "imageTestFailed"
+	TemplateImagesScanned        = "imagesScanned"
+	TemplateImagesScanFailed     = "imagesScanFailed"
+	TemplateImagePushing         = "imagePushing"
+	TemplateImagePushed          = "imagePushed"
+	TemplateImagePushFailed      = "imagePushFailed"
+	TemplateConfigurationValid   = "configurationValid"
+	TemplateConfigurationInvalid = "configurationInvalid"
+	TemplateDeploying            = "deploying"
+	TemplateDeployed             = "deployed"
+	TemplateDeployFailed         = "deployFailed"
+	TemplateBuildAttachment      = "buildAttachment"
+	TemplateProjectAttachment    = "projectAttachment"
+	TemplateImageAttachment      = "imageAttachment"
+	TemplateChangelogHeader      = "changelogHeader"
+	TemplateChangelogAttachment  = "changelogAttachment"
+	TemplateOutputAttachment     = "outputAttachment"
+	TemplateTestsAttachment      = "testsAttachment"
+	TemplateScanAttachment       = "scanAttachment"
+	TemplateErrorHeader          = "errorHeader"
+	TemplateErrorAttachment      = "errorAttachment"
+)
+
+// DefaultTemplates are used for every message not overridden in notifications.templates.
+var DefaultTemplates = map[string]string{
+	TemplateReleaseStarted:       ":rocket: *{{ .ProjectFullName }}* is being run with steps `{{ .Steps }}` on *{{ .Environment }}* with version `{{ .ProjectVersion }}` :see_no_evil:",
+	TemplateReleaseSucceeded:     "`{{ .Steps }}` ended *successfully* :heart:",
+	TemplateReleaseFailed:        "{{ .Steps }} has *failed* :cry:",
+	TemplateImageBuilding:        "Container *{{ .ImageName }}* is being built...",
+	TemplateImageBuilt:           "Container *{{ .ImageName }}* was built successfully :grin:",
+	TemplateImageBuildFailed:     "Container *{{ .ImageName }}* failed to build :cry:",
+	TemplateImageCache:           "Container *{{ .ImageName }}* reused *{{ .CacheHits }}* of {{ .CacheSteps }} build steps from cache :zap:",
+	TemplateImageReused:          "Container *{{ .ImageName }}* is unchanged, reusing `{{ .ReusedImage }}` :recycle:",
+	TemplateImageTested:          "Container *{{ .ImageName }}* passed {{ .TestsTotal }} tests :white_check_mark:",
+	TemplateImageTestFailed:      "Container *{{ .ImageName }}* passed *{{ .TestsPassed }}* of {{ .TestsTotal }} tests :cry:",
+	TemplateImagesScanned:        "Containers were scanned for vulnerabilities, nothing at or above the threshold :shield:",
+	TemplateImagesScanFailed:     "Container scan has *failed*{{ if .ScanBlocking }} with *{{ .ScanBlocking }}* vulnerabilities at or above the threshold{{ end }} :rotating_light:",
+	TemplateImagePushing:         "Container {{ .ImageName }} is being pushed... :boat:",
+	TemplateImagePushed:          "Container *{{ .ImageName }}* was successfully pushed to registry :grin:",
+	TemplateImagePushFailed:      "Container *{{ .ImageName }}* failed to push to registry :cry:",
+	TemplateConfigurationValid:   "Kubernetes deployment configuration is valid :small_airplane:",
+	TemplateConfigurationInvalid: "Kubernetes deployment configuration is invalid :cry:",
+	TemplateDeploying:            "Deploying to *{{ .Environment }}* cluster *{{ .KubernetesCluster }}*... :rocket:",
+	TemplateDeployed:             "Deployed successfully to *{{ .Environment }}* :trophy:",
+	TemplateDeployFailed:         "Failed to deploy to *{{ .Environment }}* :tired_face:",
+	TemplateBuildAttachment: `Build platform: *{{ .BuildPlatform }}*
+Build url: {{ .BuildUrl }}
+Repository: {{ .BuildRepository }}
+Commit: *{{ .BuildCommit }}*
+Author: *{{ .CommitAuthor }}*
+Subject: {{ .CommitSubject }}
+Branch: *{{if .BuildBranch }}{{ .BuildBranch }}{{else}}n/a{{end}}*
+Tag: *{{if .BuildTag }}{{ .BuildTag }}{{else}}n/a{{end}}*
+Version: *{{ .ProjectVersion }}*
+Environment: *{{ .Environment }}*`,
+	TemplateProjectAttachment: `Version: *{{ .ProjectVersion }}*
+Environment: *{{ .Environment }}*
+Cluster: *{{ .KubernetesCluster }}*
+Zone: *{{ .KubernetesZone }}*
+Project: *{{ .CloudProject }}*
+Registry: {{ .CloudRegistry }}
+`,
+	TemplateImageAttachment: `Container name: *{{ .ImageName }}*
+Registry: {{ index .ImageRepositories .ImageName }}:{{ .ProjectVersionTag }}`,
+	TemplateChangelogHeader: "Changes since {{ .DeployedCommit }}",
+	TemplateChangelogAttachment: "{{ range .Changelog }}`{{ .ShortHash }}` {{ .Subject }} _({{ .Author }})_\n" +
+		"{{ end }}",
+	TemplateOutputAttachment: "```{{ .Output }}```",
+	TemplateTestsAttachment: "{{ range .Tests }}{{ if .Passed }}:white_check_mark:{{ else }}:x:{{ end }} {{ .Name }}" +
+		"{{ if .Message }} _{{ .Message }}_{{ end }}\n{{ end }}",
+	TemplateScanAttachment: "```{{ printf \"%-30s %8s %8s %8s %8s %8s %8s\" \"Image\" \"CRITICAL\" \"HIGH\" \"MEDIUM\" \"LOW\" \"UNKNOWN\" \"Blocking\" }}\n" +
+		"{{ range .Scans }}{{ printf \"%-30s %8d %8d %8d %8d %8d %8d\" .Image (index .Counts \"CRITICAL\") (index .Counts \"HIGH\") " +
+		"(index .Counts \"MEDIUM\") (index .Counts \"LOW\") (index .Counts \"UNKNOWN\") .Blocking }}\n{{ end }}```",
+	TemplateErrorHeader:     "Error details",
+	TemplateErrorAttachment: "{{ .Error }}",
+}
@@ -1,11 +1,13 @@
 package platforms
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
 
 type BitbucketPlatform struct {
+	repository *LocalGitRepositoryPlatform
 }
 
 func (b BitbucketPlatform) IsDetected() bool {
@@ -24,12 +26,28 @@ func (b BitbucketPlatform) CurrentCommit() string {
 	return ""
 }
 
-func (b BitbucketPlatform) CurrentTag() string {
-	if value, ok := os.LookupEnv("BITBUCKET_TAG"); ok {
-		return value
+func (b BitbucketPlatform) CurrentCommitDetails() Commit {
+	if b.repository == nil {
+		return Commit{Hash: b.CurrentCommit()}
 	}
 
-	return ""
+	return b.repository.CurrentCommitDetails()
+}
+
+func (b BitbucketPlatform) Changelog(since string) ([]Commit, error) {
+	if b.repository == nil {
+		return []Commit{}, errors.New("RepositoryNotFound")
+	}
+
+	return b.repository.Changelog(since)
+}
+
+func (b BitbucketPlatform) CurrentTags() []string {
+	if value, ok := os.LookupEnv("BITBUCKET_TAG"); ok && value != "" {
+		return []string{value}
+	}
+
+	return []string{}
 }
 
 func (b BitbucketPlatform) CurrentBranch() string {
@@ -40,12 +58,20 @@ func (b BitbucketPlatform) CurrentBranch() string {
 	return ""
 }
 
-func (b BitbucketPlatform) CurrentBuildNumber() string {
+func (b BitbucketPlatform) CurrentBuildNumber() (string, error) {
 	if value, ok := os.LookupEnv("BITBUCKET_BUILD_NUMBER"); ok {
-		return value
+		return value, nil
 	}
 
-	return ""
+	return "", errors.New("BuildNumberNotFound(BITBUCKET_BUILD_NUMBER)")
+}
+
+func (b BitbucketPlatform) IsDirty() bool {
+	if b.repository == nil {
+		return false
+	}
+
+	return b.repository.IsDirty()
 }
 
 func (b BitbucketPlatform) Name() string {
@@ -0,0 +1,31 @@
+package platforms
+
+import (
+	"strings"
+	"time"
+)
+
+const shortHashLength = 7
+
+type Commit struct {
+	Hash           string
+	Author         string
+	AuthorEmail    string
+	Committer      string
+	CommitterEmail string
+	Subject        string
+	Message        string
+	When           time.Time
+}
+
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > shortHashLength {
+		return c.Hash[:shortHashLength]
+	}
+
+	return c.Hash
+}
+
+func subjectOf(message string) string {
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(message), "\n", 2)[0])
+}
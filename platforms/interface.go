@@ -2,9 +2,12 @@ package platforms
 
 type Platform interface {
 	CurrentCommit() string
-	CurrentTag() string
+	CurrentCommitDetails() Commit
+	Changelog(since string) ([]Commit, error)
+	CurrentTags() []string
 	CurrentBranch() string
-	CurrentBuildNumber() string
+	CurrentBuildNumber() (string, error)
+	IsDirty() bool
 	IsDetected() bool
 	Name() string
 	BuildUrl() string
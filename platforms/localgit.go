@@ -1,20 +1,31 @@
 package platforms
 
 import (
+	"errors"
 	"fmt"
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+const detachedHead = "HEAD"
+const maxChangelogEntries = 50
+
 type LocalGitRepositoryPlatform struct {
 	repo *git.Repository
 }
 
 func NewLocalGitRepositoryPlatform() (*LocalGitRepositoryPlatform, error) {
-	repository, err := git.PlainOpen(".")
+	return NewLocalGitRepositoryPlatformAt(".")
+}
+
+func NewLocalGitRepositoryPlatformAt(path string) (*LocalGitRepositoryPlatform, error) {
+	repository, err := git.PlainOpen(path)
 	if err != nil {
-		fmt.Printf("Error is :%s", err)
 		return nil, err
 	}
 
@@ -37,27 +48,151 @@ func (l LocalGitRepositoryPlatform) CurrentCommit() string {
 	return ref.Hash().String()
 }
 
-func (l LocalGitRepositoryPlatform) CurrentTag() string {
+func (l LocalGitRepositoryPlatform) CurrentCommitDetails() Commit {
 	ref, err := l.repo.Head()
-	tag := ""
+	if err != nil {
+		return Commit{}
+	}
 
+	commit, err := l.repo.CommitObject(ref.Hash())
 	if err != nil {
-		return "unknown"
+		return Commit{Hash: ref.Hash().String()}
+	}
+
+	return commitDetails(commit)
+}
+
+// Changelog returns commits reachable from HEAD but not from the given commit, newest
+// first. An empty since returns an empty changelog as there is nothing to compare with.
+func (l LocalGitRepositoryPlatform) Changelog(since string) ([]Commit, error) {
+	if since == "" {
+		return make([]Commit, 0), nil
+	}
+
+	return l.commitsSince(since, maxChangelogEntries)
+}
+
+func (l LocalGitRepositoryPlatform) commitsSince(since string, limit int) ([]Commit, error) {
+	commits := make([]Commit, 0)
+	released := make(map[plumbing.Hash]bool)
+
+	ref, err := l.repo.Head()
+	if err != nil {
+		return commits, err
+	}
+
+	if since != "" {
+		base, err := l.repo.CommitObject(plumbing.NewHash(since))
+		if err != nil {
+			return commits, errors.New(fmt.Sprintf("CommitNotFound(%s)", since))
+		}
+
+		if err := object.NewCommitPreorderIter(base, nil, nil).ForEach(func(c *object.Commit) error {
+			released[c.Hash] = true
+			return nil
+		}); err != nil {
+			return commits, err
+		}
+	}
+
+	log, err := l.repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return commits, err
+	}
+
+	err = log.ForEach(func(c *object.Commit) error {
+		if released[c.Hash] {
+			return nil
+		}
+
+		if len(commits) == limit {
+			return storer.ErrStop
+		}
+
+		commits = append(commits, commitDetails(c))
+		return nil
+	})
+
+	return commits, err
+}
+
+func commitDetails(commit *object.Commit) Commit {
+	return Commit{
+		Hash:           commit.Hash.String(),
+		Author:         commit.Author.Name,
+		AuthorEmail:    commit.Author.Email,
+		Committer:      commit.Committer.Name,
+		CommitterEmail: commit.Committer.Email,
+		Subject:        subjectOf(commit.Message),
+		Message:        strings.TrimSpace(commit.Message),
+		When:           commit.Committer.When,
 	}
+}
 
-	tags, err := l.repo.TagObjects()
+// CurrentTags returns names of tags pointing at HEAD in lexical order. Both lightweight and
+// annotated tags are taken into account.
+func (l LocalGitRepositoryPlatform) CurrentTags() []string {
+	ref, err := l.repo.Head()
+	if err != nil {
+		return []string{}
+	}
 
-	tags.ForEach(func(t *object.Tag) error {
-		if t.Target.String() == ref.Hash().String() {
-			tag = t.Name
+	tags, err := l.tagsPointingAt(ref.Hash())
+	if err != nil {
+		return []string{}
+	}
+
+	return tags
+}
+
+func (l LocalGitRepositoryPlatform) tagsPointingAt(hash plumbing.Hash) ([]string, error) {
+	names := make([]string, 0)
+
+	refs, err := l.repo.Tags()
+	if err != nil {
+		return names, err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		target, err := l.tagTarget(ref)
+		if err != nil {
+			return nil
+		}
+
+		if target == hash {
+			names = append(names, ref.Name().Short())
 		}
 
 		return nil
 	})
 
-	return tag
+	sort.Strings(names)
+
+	return names, err
+}
+
+// tagTarget resolves the commit a tag reference points at. Lightweight tags point at the
+// commit directly, annotated tags point at a tag object which has to be peeled first.
+func (l LocalGitRepositoryPlatform) tagTarget(ref *plumbing.Reference) (plumbing.Hash, error) {
+	tag, err := l.repo.TagObject(ref.Hash())
+
+	switch err {
+	case nil:
+		commit, err := tag.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		return commit.Hash, nil
+	case plumbing.ErrObjectNotFound:
+		return ref.Hash(), nil
+	default:
+		return plumbing.ZeroHash, err
+	}
 }
 
+// CurrentBranch returns the checked out branch. On a detached HEAD (which is how most CI
+// servers clone) the branch is resolved from local and then remote refs pointing at HEAD.
 func (l LocalGitRepositoryPlatform) CurrentBranch() string {
 	ref, err := l.repo.Head()
 
@@ -65,11 +200,113 @@ func (l LocalGitRepositoryPlatform) CurrentBranch() string {
 		return "unknown"
 	}
 
-	return strings.TrimPrefix(ref.Name().String(), "refs/heads/")
+	if ref.Name().IsBranch() {
+		return ref.Name().Short()
+	}
+
+	if branch := l.branchPointingAt(ref.Hash()); branch != "" {
+		return branch
+	}
+
+	return detachedHead
+}
+
+func (l LocalGitRepositoryPlatform) branchPointingAt(hash plumbing.Hash) string {
+	refs, err := l.repo.References()
+	if err != nil {
+		return ""
+	}
+
+	local := make([]string, 0)
+	remote := make([]string, 0)
+
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || ref.Hash() != hash {
+			return nil
+		}
+
+		switch {
+		case ref.Name().IsBranch():
+			local = append(local, ref.Name().Short())
+		case ref.Name().IsRemote():
+			// refs/remotes/<remote>/<branch>
+			parts := strings.SplitN(strings.TrimPrefix(ref.Name().String(), "refs/remotes/"), "/", 2)
+
+			if len(parts) == 2 && parts[1] != detachedHead {
+				remote = append(remote, parts[1])
+			}
+		}
+
+		return nil
+	})
+
+	for _, candidates := range [][]string{local, remote} {
+		if len(candidates) > 0 {
+			sort.Strings(candidates)
+			return candidates[0]
+		}
+	}
+
+	return ""
+}
+
+// CurrentBuildNumber returns the number of commits reachable from HEAD, which grows
+// monotonically along a branch and is the same on every full clone. Shallow clones miss
+// part of the history, so the number can't be computed there.
+func (l LocalGitRepositoryPlatform) CurrentBuildNumber() (string, error) {
+	shallow, err := l.repo.Storer.Shallow()
+	if err != nil {
+		return "", err
+	}
+
+	if len(shallow) > 0 {
+		return "", errors.New("ShallowRepository: build number is computed from the commit history, fetch it with git fetch --unshallow")
+	}
+
+	ref, err := l.repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	commits, err := l.repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return "", err
+	}
+
+	count := 0
+
+	err = commits.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+
+	return strconv.Itoa(count), err
 }
 
-func (l LocalGitRepositoryPlatform) CurrentBuildNumber() string {
-	return "0"
+// IsDirty reports whether tracked files have uncommitted changes. Like `git describe --dirty`
+// untracked files are ignored.
+func (l LocalGitRepositoryPlatform) IsDirty() bool {
+	worktree, err := l.repo.Worktree()
+	if err != nil {
+		return false
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return false
+	}
+
+	for _, file := range status {
+		if file.Worktree == git.Untracked && file.Staging == git.Untracked {
+			continue
+		}
+
+		if file.Worktree != git.Unmodified || file.Staging != git.Unmodified {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (l LocalGitRepositoryPlatform) Name() string {
@@ -0,0 +1,227 @@
+package platforms
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type testRepository struct {
+	t        *testing.T
+	dir      string
+	repo     *git.Repository
+	worktree *git.Worktree
+	platform *LocalGitRepositoryPlatform
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	dir, err := ioutil.TempDir("", "gcp-builder-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	platform, err := NewLocalGitRepositoryPlatformAt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testRepository{t, dir, repo, worktree, platform}
+}
+
+func (r *testRepository) Close() {
+	os.RemoveAll(r.dir)
+}
+
+func (r *testRepository) write(name string, content string) {
+	if err := ioutil.WriteFile(filepath.Join(r.dir, name), []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepository) commit(content string) plumbing.Hash {
+	r.write("file", content)
+
+	if _, err := r.worktree.Add("file"); err != nil {
+		r.t.Fatal(err)
+	}
+
+	hash, err := r.worktree.Commit(content, &git.CommitOptions{Author: signature()})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	return hash
+}
+
+func (r *testRepository) lightweightTag(name string, hash plumbing.Hash) {
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName(name), hash)); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepository) annotatedTag(name string, hash plumbing.Hash) {
+	if _, err := r.repo.CreateTag(name, hash, &git.CreateTagOptions{Tagger: signature(), Message: name}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func signature() *object.Signature {
+	return &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+}
+
+func TestCurrentTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		lightweight []string
+		annotated   []string
+		expected    string
+	}{
+		{"no tags", nil, nil, ""},
+		{"lightweight tag", []string{"v1.0.0"}, nil, "v1.0.0"},
+		{"annotated tag", nil, []string{"v1.0.0"}, "v1.0.0"},
+		{"mixed tags", []string{"v1.0.0", "stable"}, []string{"v1.1.0"}, "stable, v1.0.0, v1.1.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := newTestRepository(t)
+			defer repository.Close()
+
+			previous := repository.commit("previous")
+			head := repository.commit("head")
+
+			repository.lightweightTag("v9.9.9", previous)
+
+			for _, name := range test.lightweight {
+				repository.lightweightTag(name, head)
+			}
+
+			for _, name := range test.annotated {
+				repository.annotatedTag(name, head)
+			}
+
+			if tags := strings.Join(repository.platform.CurrentTags(), ", "); tags != test.expected {
+				t.Errorf("expected tags %q, got %q", test.expected, tags)
+			}
+		})
+	}
+}
+
+func TestCurrentBranch(t *testing.T) {
+	repository := newTestRepository(t)
+	defer repository.Close()
+
+	head := repository.commit("head")
+
+	if branch := repository.platform.CurrentBranch(); branch != "master" {
+		t.Errorf("expected branch master, got %q", branch)
+	}
+
+	// CI servers check out the commit with a detached HEAD and only fetch remote refs
+	repository.repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/HEAD", head))
+	repository.repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/feature/login", head))
+	repository.repo.Storer.RemoveReference(plumbing.Master)
+	repository.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, head))
+
+	if branch := repository.platform.CurrentBranch(); branch != "feature/login" {
+		t.Errorf("expected branch feature/login, got %q", branch)
+	}
+
+	repository.repo.Storer.RemoveReference("refs/remotes/origin/feature/login")
+
+	if branch := repository.platform.CurrentBranch(); branch != detachedHead {
+		t.Errorf("expected branch %s, got %q", detachedHead, branch)
+	}
+}
+
+func TestCurrentBuildNumber(t *testing.T) {
+	repository := newTestRepository(t)
+	defer repository.Close()
+
+	for i, content := range []string{"first", "second", "third"} {
+		repository.commit(content)
+
+		number, err := repository.platform.CurrentBuildNumber()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if number != strconv.Itoa(i+1) {
+			t.Errorf("expected build number %d, got %q", i+1, number)
+		}
+	}
+}
+
+func TestCurrentBuildNumberOfShallowClone(t *testing.T) {
+	repository := newTestRepository(t)
+	defer repository.Close()
+
+	repository.commit("first")
+	head := repository.commit("second")
+
+	if err := repository.repo.Storer.SetShallow([]plumbing.Hash{head}); err != nil {
+		t.Fatal(err)
+	}
+
+	if number, err := repository.platform.CurrentBuildNumber(); err == nil || !strings.Contains(err.Error(), "ShallowRepository") {
+		t.Errorf("expected ShallowRepository error, got %q (%v)", number, err)
+	}
+}
+
+func TestIsDirty(t *testing.T) {
+	repository := newTestRepository(t)
+	defer repository.Close()
+
+	repository.commit("clean")
+
+	if repository.platform.IsDirty() {
+		t.Error("expected clean worktree")
+	}
+
+	repository.write("untracked", "untracked")
+
+	if repository.platform.IsDirty() {
+		t.Error("expected worktree with untracked files only to be clean")
+	}
+
+	repository.write("file", "modified")
+
+	if !repository.platform.IsDirty() {
+		t.Error("expected worktree with modified files to be dirty")
+	}
+
+	if !(BitbucketPlatform{repository.platform}).IsDirty() {
+		t.Error("expected Bitbucket to report the local worktree as dirty")
+	}
+}
+
+func TestOpeningDirectoryWithoutRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcp-builder-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if _, err := NewLocalGitRepositoryPlatformAt(dir); err == nil {
+		t.Error("expected error for a directory without repository")
+	}
+}
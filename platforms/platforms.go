@@ -3,12 +3,14 @@ package platforms
 import "errors"
 
 func GetAll() []Platform {
+	repository, _ := NewLocalGitRepositoryPlatform()
+
 	platforms := []Platform{
-		&BitbucketPlatform{},
+		&BitbucketPlatform{repository},
 	}
 
-	if platform, err := NewLocalGitRepositoryPlatform(); err == nil {
-		platforms = append(platforms, *platform)
+	if repository != nil {
+		platforms = append(platforms, *repository)
 	}
 
 	return platforms
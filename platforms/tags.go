@@ -0,0 +1,117 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"os"
+	"time"
+)
+
+const defaultTaggerName = "gcp-builder"
+const defaultTaggerEmail = "gcp-builder@localhost"
+
+type Tag struct {
+	Name   string
+	Commit string
+}
+
+// Tags returns all lightweight and annotated tags with the commits they point at.
+func (l LocalGitRepositoryPlatform) Tags() ([]Tag, error) {
+	tags := make([]Tag, 0)
+
+	refs, err := l.repo.Tags()
+	if err != nil {
+		return tags, err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		target, err := l.tagTarget(ref)
+		if err != nil {
+			return nil
+		}
+
+		tags = append(tags, Tag{
+			Name:   ref.Name().Short(),
+			Commit: target.String(),
+		})
+
+		return nil
+	})
+
+	return tags, err
+}
+
+// CommitsSince returns all commits reachable from HEAD but not from the given commit,
+// newest first. An empty since returns the whole history.
+func (l LocalGitRepositoryPlatform) CommitsSince(since string) ([]Commit, error) {
+	return l.commitsSince(since, -1)
+}
+
+// CreateTag creates an annotated tag pointing at HEAD.
+func (l LocalGitRepositoryPlatform) CreateTag(name string, message string) error {
+	if _, err := l.repo.Tag(name); err == nil {
+		return errors.New(fmt.Sprintf("TagAlreadyExists(%s)", name))
+	}
+
+	ref, err := l.repo.Head()
+	if err != nil {
+		return err
+	}
+
+	_, err = l.repo.CreateTag(name, ref.Hash(), &git.CreateTagOptions{
+		Tagger:  l.tagger(),
+		Message: message,
+	})
+
+	return err
+}
+
+// PushTag pushes a single tag to the origin remote.
+func (l LocalGitRepositoryPlatform) PushTag(name string) error {
+	refSpec := config.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", name, name))
+
+	err := l.repo.Push(&git.PushOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{refSpec},
+	})
+
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+
+	return err
+}
+
+func (l LocalGitRepositoryPlatform) tagger() *object.Signature {
+	signature := &object.Signature{
+		Name:  defaultTaggerName,
+		Email: defaultTaggerEmail,
+		When:  time.Now(),
+	}
+
+	if cfg, err := l.repo.Config(); err == nil {
+		user := cfg.Raw.Section("user")
+
+		if name := user.Option("name"); name != "" {
+			signature.Name = name
+		}
+
+		if email := user.Option("email"); email != "" {
+			signature.Email = email
+		}
+	}
+
+	if name, ok := os.LookupEnv("GIT_COMMITTER_NAME"); ok {
+		signature.Name = name
+	}
+
+	if email, ok := os.LookupEnv("GIT_COMMITTER_EMAIL"); ok {
+		signature.Email = email
+	}
+
+	return signature
+}
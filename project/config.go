@@ -9,17 +9,24 @@ import (
 type Variables []Variable
 
 type Configuration struct {
-	Project      Project        `yaml:"project"`
-	Environments []*Environment `yaml:"environments"`
-	Images       []Image        `yaml:"images"`
-	Variables    Variables      `yaml:"variables"`
+	Project       Project        `yaml:"project"`
+	Environments  []*Environment `yaml:"environments"`
+	Images        []Image        `yaml:"images"`
+	Variables     Variables      `yaml:"variables"`
+	Notifications Notifications  `yaml:"notifications"`
+}
+
+type Notifications struct {
+	Templates map[string]string `yaml:"templates"`
 }
 
 type Project struct {
-	Name          string `yaml:"name"`
-	Domain        string `yaml:"domain"`
-	Context       string `yaml:"context"`
-	VersionPrefix string `yaml:"versionPrefix"`
+	Name            string `yaml:"name"`
+	Domain          string `yaml:"domain"`
+	Context         string `yaml:"context"`
+	VersionPrefix   string `yaml:"versionPrefix"`
+	VersionTemplate string `yaml:"versionTemplate"`
+	Url             string `yaml:"url"`
 }
 
 func (p Project) FullName() string {
@@ -27,10 +34,29 @@ func (p Project) FullName() string {
 }
 
 type Environment struct {
-	Name       string      `yaml:"name"`
-	ServiceKey string      `yaml:"key"`
-	Kubernetes Kubernetes  `yaml:"kubernetes"`
-	Cloud      GoogleCloud `yaml:"gcloud"`
+	Name        string       `yaml:"name"`
+	ServiceKey  string       `yaml:"key"`
+	Kubernetes  Kubernetes   `yaml:"kubernetes"`
+	Cloud       GoogleCloud  `yaml:"gcloud"`
+	ConfigFiles []ConfigFile `yaml:"configFiles"`
+	Secrets     []Secret     `yaml:"secrets"`
+	Images      []Image      `yaml:"images"`
+	Builder     string       `yaml:"builder"`
+	Tags        []TagPolicy  `yaml:"tags"`
+	Registries  []Registry   `yaml:"registries"`
+	Scan        *Scan        `yaml:"scan"`
+}
+
+type Secret struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	Type string `yaml:"type"`
+}
+
+type ConfigFile struct {
+	Name     string `yaml:"name"`
+	Path     string `yaml:"path"`
+	Template bool   `yaml:"template"`
 }
 
 func (e *Environment) envKey(key string) string {
@@ -42,11 +68,63 @@ type GoogleCloud struct {
 	Project  string `yaml:"project"`
 }
 
+// Registry is an image registry referenced by name from images. Credentials are read from
+// the named environment variables, without them docker config, credential helpers and gcloud
+// access tokens are used.
+type Registry struct {
+	Name             string `yaml:"name"`
+	Url              string `yaml:"url"`
+	UsernameVariable string `yaml:"usernameVariable"`
+	PasswordVariable string `yaml:"passwordVariable"`
+}
+
+// Scan configures the scan step. Findings at or above Severity fail the step unless their
+// ids are allowed. Command replaces the command of the scanner, {{ .Image }} is replaced
+// with the scanned image.
+type Scan struct {
+	Scanner  string   `yaml:"scanner"`
+	Command  []string `yaml:"command"`
+	Format   string   `yaml:"format"`
+	Severity string   `yaml:"severity"`
+	Allow    []string `yaml:"allow"`
+}
+
+// Cache configures layer cache reuse: sources are "branch", "default" (the default branch)
+// or a tag of the image repository, the cache of the current branch is exported inline or
+// to the registry after the build.
+type Cache struct {
+	From          []string `yaml:"from"`
+	DefaultBranch string   `yaml:"defaultBranch"`
+	Export        string   `yaml:"export"`
+}
+
+// TagPolicy is an additional image tag template. With branches or tags patterns it is
+// pushed only when the current branch or git tag matches one of them.
+type TagPolicy struct {
+	Name     string   `yaml:"name"`
+	Branches []string `yaml:"branches"`
+	Tags     []string `yaml:"tags"`
+}
+
 type Kubernetes struct {
-	Cluster   string    `yaml:"cluster"`
-	Zone      string    `yaml:"zone"`
-	Template  string    `yaml:"template"`
-	Variables Variables `yaml:"variables"`
+	Cluster   string     `yaml:"cluster"`
+	Zone      string     `yaml:"zone"`
+	Namespace string     `yaml:"namespace"`
+	Template  string     `yaml:"template"`
+	Variables Variables  `yaml:"variables"`
+	Helm      *Helm      `yaml:"helm"`
+	Kustomize *Kustomize `yaml:"kustomize"`
+}
+
+type Kustomize struct {
+	Path string `yaml:"path"`
+}
+
+type Helm struct {
+	Chart     string   `yaml:"chart"`
+	Release   string   `yaml:"release"`
+	Namespace string   `yaml:"namespace"`
+	Values    []string `yaml:"values"`
 }
 
 type Variable struct {
@@ -54,10 +132,60 @@ type Variable struct {
 	Value string `yaml:"value"`
 }
 
+// DefaultDockerfile is used by images which don't set dockerfile
+const DefaultDockerfile = "Dockerfile"
+
 type Image struct {
-	Build      string `yaml:"build"`
-	Name       string `yaml:"name"`
-	Dockerfile string `yaml:"dockerfile"`
+	Build      string            `yaml:"build"`
+	Name       string            `yaml:"name"`
+	Dockerfile string            `yaml:"dockerfile"`
+	BuildArgs  map[string]string `yaml:"buildArgs"`
+	Target     string            `yaml:"target"`
+	Labels     map[string]string `yaml:"labels"`
+	Network    string            `yaml:"network"`
+	Secrets    []string          `yaml:"secrets"`
+	Platform   string            `yaml:"platform"`
+	Platforms  []string          `yaml:"platforms"`
+	Builder    string            `yaml:"builder"`
+	Registry   string            `yaml:"registry"`
+	Cache      *Cache            `yaml:"cache"`
+	Tags       []TagPolicy       `yaml:"tags"`
+	// Reuse retags an image with the same content hash instead of building it again
+	Reuse *bool `yaml:"reuse"`
+	// BuilderLabels disables gcp-builder.* labels when set to false
+	BuilderLabels *bool `yaml:"builderLabels"`
+	// Tests are run against the built image in the test-images step
+	Tests *ImageTests `yaml:"tests"`
+}
+
+type ImageTests struct {
+	Commands []CommandTest `yaml:"commands"`
+	Files    []FileTest    `yaml:"files"`
+	Metadata *MetadataTest `yaml:"metadata"`
+}
+
+// CommandTest runs the command as the entrypoint of the image. Output is matched against
+// regular expressions, timeout is a duration defaulting to 60s.
+type CommandTest struct {
+	Name           string            `yaml:"name"`
+	Command        []string          `yaml:"command"`
+	Env            map[string]string `yaml:"env"`
+	ExitCode       int               `yaml:"exitCode"`
+	ExpectedOutput []string          `yaml:"expectedOutput"`
+	ExcludedOutput []string          `yaml:"excludedOutput"`
+	Timeout        string            `yaml:"timeout"`
+}
+
+// FileTest checks that the path exists in the image or is absent with exists: false.
+type FileTest struct {
+	Path   string `yaml:"path"`
+	Exists *bool  `yaml:"exists"`
+}
+
+type MetadataTest struct {
+	ExposedPorts []string `yaml:"exposedPorts"`
+	Entrypoint   []string `yaml:"entrypoint"`
+	NonRoot      bool     `yaml:"nonRoot"`
 }
 
 func (vars Variables) FindByName(key string) (string, error) {
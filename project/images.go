@@ -0,0 +1,97 @@
+package project
+
+// ImagesFor returns project images with overrides of the given environment applied.
+func (c *Configuration) ImagesFor(env *Environment) []Image {
+	images := make([]Image, 0)
+
+	for _, image := range c.Images {
+		for _, override := range env.Images {
+			if override.Name == image.Name {
+				image = image.Override(override)
+			}
+		}
+
+		images = append(images, image)
+	}
+
+	return images
+}
+
+// Override returns the image with every field set in override replaced. Build args and
+// labels are merged.
+func (i Image) Override(override Image) Image {
+	if override.Build != "" {
+		i.Build = override.Build
+	}
+
+	if override.Dockerfile != "" {
+		i.Dockerfile = override.Dockerfile
+	}
+
+	if override.Target != "" {
+		i.Target = override.Target
+	}
+
+	if override.Network != "" {
+		i.Network = override.Network
+	}
+
+	if override.Platform != "" {
+		i.Platform = override.Platform
+	}
+
+	if len(override.Platforms) > 0 {
+		i.Platforms = override.Platforms
+	}
+
+	if override.Builder != "" {
+		i.Builder = override.Builder
+	}
+
+	if override.Registry != "" {
+		i.Registry = override.Registry
+	}
+
+	if override.Cache != nil {
+		i.Cache = override.Cache
+	}
+
+	if override.Reuse != nil {
+		i.Reuse = override.Reuse
+	}
+
+	if override.BuilderLabels != nil {
+		i.BuilderLabels = override.BuilderLabels
+	}
+
+	if override.Tests != nil {
+		i.Tests = override.Tests
+	}
+
+	if len(override.Tags) > 0 {
+		i.Tags = override.Tags
+	}
+
+	if len(override.Secrets) > 0 {
+		i.Secrets = override.Secrets
+	}
+
+	i.BuildArgs = mergeMaps(i.BuildArgs, override.BuildArgs)
+	i.Labels = mergeMaps(i.Labels, override.Labels)
+
+	return i
+}
+
+func mergeMaps(base map[string]string, override map[string]string) map[string]string {
+	merged := make(map[string]string)
+
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		merged[key] = value
+	}
+
+	return merged
+}
@@ -0,0 +1,43 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// RegistryFor returns the registry the image is pushed to: the environment registry named by
+// the image, a registry url set on the image or the default gcloud registry.
+func (e *Environment) RegistryFor(image Image) Registry {
+	if image.Registry == "" {
+		return Registry{Name: "default", Url: e.Cloud.Registry}
+	}
+
+	for _, registry := range e.Registries {
+		if registry.Name == image.Registry {
+			return registry
+		}
+	}
+
+	return Registry{Name: image.Registry, Url: image.Registry}
+}
+
+// Credentials reads explicit credentials from the configured environment variables.
+func (r Registry) Credentials() (string, string, bool, error) {
+	if r.UsernameVariable == "" && r.PasswordVariable == "" {
+		return "", "", false, nil
+	}
+
+	username, password := os.Getenv(r.UsernameVariable), os.Getenv(r.PasswordVariable)
+
+	if username == "" || password == "" {
+		return "", "", false, errors.New(fmt.Sprintf(
+			"RegistryCredentialsMissing(%s): set %s and %s",
+			r.Name,
+			r.UsernameVariable,
+			r.PasswordVariable,
+		))
+	}
+
+	return username, password, true, nil
+}
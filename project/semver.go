@@ -0,0 +1,178 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// https://semver.org/#is-there-a-suggested-regular-expression-regex-to-check-a-semver-string
+var semverPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+const dirtySuffix = "dirty"
+
+// Version is either a semantic version parsed from a release tag or a snapshot version
+// built from the current branch.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Build      string
+	Branch     string
+	Dirty      bool
+	snapshot   string
+}
+
+func ParseVersion(version string) (Version, error) {
+	parts := semverPattern.FindStringSubmatch(version)
+	if parts == nil {
+		return Version{}, errors.New(fmt.Sprintf("InvalidVersion(%s)", version))
+	}
+
+	numbers := make([]uint64, 3)
+
+	for i := range numbers {
+		number, err := strconv.ParseUint(parts[i+1], 10, 64)
+		if err != nil {
+			return Version{}, errors.New(fmt.Sprintf("InvalidVersion(%s)", version))
+		}
+
+		numbers[i] = number
+	}
+
+	return Version{
+		Major:      numbers[0],
+		Minor:      numbers[1],
+		Patch:      numbers[2],
+		Prerelease: parts[4],
+		Build:      parts[5],
+	}, nil
+}
+
+func NewSnapshotVersion(branch string, name string) Version {
+	return Version{
+		Branch:   branch,
+		snapshot: name,
+	}
+}
+
+func (v Version) IsSnapshot() bool {
+	return v.snapshot != "" || v.Dirty
+}
+
+func (v Version) IsPrerelease() bool {
+	return v.snapshot == "" && v.Prerelease != ""
+}
+
+func (v Version) String() string {
+	var version string
+
+	if v.snapshot != "" {
+		version = v.snapshot
+	} else {
+		version = v.SemVer()
+	}
+
+	if v.Dirty {
+		return fmt.Sprintf("%s-%s", version, dirtySuffix)
+	}
+
+	return version
+}
+
+// Tag returns the version as a valid Docker tag. It is sanitized once, after the dirty
+// suffix is added, so long snapshot names are truncated and hashed only once.
+func (v Version) Tag() string {
+	return SanitizeTag(v.String())
+}
+
+// SemVer returns the semantic version without snapshot and dirty markers.
+func (v Version) SemVer() string {
+	version := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+
+	if v.Prerelease != "" {
+		version = fmt.Sprintf("%s-%s", version, v.Prerelease)
+	}
+
+	if v.Build != "" {
+		version = fmt.Sprintf("%s+%s", version, v.Build)
+	}
+
+	return version
+}
+
+// Compare returns -1, 0 or 1 depending on semver precedence of both versions.
+// Build metadata does not take part in comparison.
+func (v Version) Compare(other Version) int {
+	for _, pair := range [][2]uint64{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+func (v Version) LessThan(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+func comparePrerelease(left, right string) int {
+	switch {
+	case left == right:
+		return 0
+	case left == "":
+		return 1
+	case right == "":
+		return -1
+	}
+
+	leftIds := strings.Split(left, ".")
+	rightIds := strings.Split(right, ".")
+
+	for i := 0; i < len(leftIds) && i < len(rightIds); i++ {
+		if result := compareIdentifier(leftIds[i], rightIds[i]); result != 0 {
+			return result
+		}
+	}
+
+	switch {
+	case len(leftIds) < len(rightIds):
+		return -1
+	case len(leftIds) > len(rightIds):
+		return 1
+	}
+
+	return 0
+}
+
+func compareIdentifier(left, right string) int {
+	leftNumber, leftErr := strconv.ParseUint(left, 10, 64)
+	rightNumber, rightErr := strconv.ParseUint(right, 10, 64)
+
+	switch {
+	case leftErr == nil && rightErr == nil:
+		if leftNumber == rightNumber {
+			return 0
+		} else if leftNumber < rightNumber {
+			return -1
+		}
+
+		return 1
+	case leftErr == nil:
+		return -1
+	case rightErr == nil:
+		return 1
+	}
+
+	return strings.Compare(left, right)
+}
@@ -0,0 +1,257 @@
+package project
+
+import (
+	"errors"
+	"github.com/wendigo/gcp-builder/platforms"
+	"testing"
+)
+
+type testPlatform struct {
+	tags    []string
+	branch  string
+	dirty   bool
+	shallow bool
+}
+
+func (p testPlatform) CurrentCommit() string {
+	return "0123456789abcdef0123456789abcdef01234567"
+}
+
+func (p testPlatform) CurrentCommitDetails() platforms.Commit {
+	return platforms.Commit{Hash: p.CurrentCommit()}
+}
+
+func (p testPlatform) Changelog(since string) ([]platforms.Commit, error) {
+	return []platforms.Commit{}, nil
+}
+
+func (p testPlatform) CurrentTags() []string {
+	return p.tags
+}
+
+func (p testPlatform) CurrentBranch() string {
+	return p.branch
+}
+
+func (p testPlatform) CurrentBuildNumber() (string, error) {
+	if p.shallow {
+		return "", errors.New("ShallowRepository")
+	}
+
+	return "42", nil
+}
+
+func (p testPlatform) IsDirty() bool {
+	return p.dirty
+}
+
+func (p testPlatform) IsDetected() bool {
+	return true
+}
+
+func (p testPlatform) Name() string {
+	return "Test"
+}
+
+func (p testPlatform) BuildUrl() string {
+	return "n/a"
+}
+
+func (p testPlatform) RepositoryUrl() string {
+	return "n/a"
+}
+
+func TestParseValidVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		major      uint64
+		minor      uint64
+		patch      uint64
+		prerelease string
+		build      string
+	}{
+		{"0.0.0", 0, 0, 0, "", ""},
+		{"1.2.3", 1, 2, 3, "", ""},
+		{"10.20.30", 10, 20, 30, "", ""},
+		{"1.0.0-alpha", 1, 0, 0, "alpha", ""},
+		{"1.0.0-alpha.1", 1, 0, 0, "alpha.1", ""},
+		{"1.0.0-0.3.7", 1, 0, 0, "0.3.7", ""},
+		{"1.0.0-x-y-z.--", 1, 0, 0, "x-y-z.--", ""},
+		{"1.0.0+20130313144700", 1, 0, 0, "", "20130313144700"},
+		{"1.0.0-beta+exp.sha.5114f85", 1, 0, 0, "beta", "exp.sha.5114f85"},
+	}
+
+	for _, test := range tests {
+		version, err := ParseVersion(test.version)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.version, err)
+			continue
+		}
+
+		if version.Major != test.major || version.Minor != test.minor || version.Patch != test.patch ||
+			version.Prerelease != test.prerelease || version.Build != test.build {
+			t.Errorf("%s: parsed as %+v", test.version, version)
+		}
+
+		if version.SemVer() != test.version || version.IsSnapshot() {
+			t.Errorf("%s: expected release version, got %s", test.version, version.SemVer())
+		}
+	}
+}
+
+func TestParseInvalidVersion(t *testing.T) {
+	for _, version := range []string{
+		"",
+		"1",
+		"1.2",
+		"1.2.3.4",
+		"v1.2.3",
+		"01.2.3",
+		"1.02.3",
+		"1.2.03",
+		"1.2.3-",
+		"1.2.3-01",
+		"1.2.3-alpha..1",
+		"1.2.3+",
+		"1.2.3+build..1",
+		"1.2.3 ",
+		"-1.2.3",
+		"1.2.3-beta!",
+		"99999999999999999999.0.0",
+	} {
+		if parsed, err := ParseVersion(version); err == nil {
+			t.Errorf("%q: expected error, parsed as %+v", version, parsed)
+		}
+	}
+}
+
+func TestComparePrecedence(t *testing.T) {
+	ordered := []string{
+		"1.0.0-0",
+		"1.0.0-1",
+		"1.0.0-2",
+		"1.0.0-10",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			left, _ := ParseVersion(ordered[i])
+			right, _ := ParseVersion(ordered[j])
+
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+
+			if result := left.Compare(right); result != expected {
+				t.Errorf("%s compared to %s: expected %d, got %d", ordered[i], ordered[j], expected, result)
+			}
+		}
+	}
+}
+
+func TestCompareIgnoresBuildMetadata(t *testing.T) {
+	tests := []struct {
+		left  string
+		right string
+	}{
+		{"1.0.0", "1.0.0+build.1"},
+		{"1.0.0+build.1", "1.0.0+build.2"},
+		{"1.0.0-rc.1+a", "1.0.0-rc.1+b"},
+	}
+
+	for _, test := range tests {
+		left, _ := ParseVersion(test.left)
+		right, _ := ParseVersion(test.right)
+
+		if result := left.Compare(right); result != 0 {
+			t.Errorf("%s compared to %s: expected 0, got %d", test.left, test.right, result)
+		}
+	}
+}
+
+func TestDetectReleaseVersionWithPrefix(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		tag      string
+		expected string
+		release  bool
+	}{
+		{"v", "v1.2.3", "1.2.3", true},
+		{"v", "v1.2.3-rc.1+build.7", "1.2.3-rc.1+build.7", true},
+		{"release-", "release-2.0.0", "2.0.0", true},
+		{"", "3.1.4", "3.1.4", true},
+		{"v", "1.2.3", "", false},
+		{"release-", "v1.2.3", "", false},
+	}
+
+	for _, test := range tests {
+		configuration := &Configuration{Project: Project{VersionPrefix: test.prefix}}
+
+		version, err := DetectVersion(configuration, testPlatform{tags: []string{test.tag}, branch: "master"})
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.tag, err)
+			continue
+		}
+
+		if version.IsSnapshot() == test.release {
+			t.Errorf("%s with prefix %q: expected release %t", test.tag, test.prefix, test.release)
+		}
+
+		if test.release && version.SemVer() != test.expected {
+			t.Errorf("%s with prefix %q: expected %s, got %s", test.tag, test.prefix, test.expected, version.SemVer())
+		}
+	}
+}
+
+func TestDetectInvalidReleaseTag(t *testing.T) {
+	configuration := &Configuration{Project: Project{VersionPrefix: "v"}}
+
+	for _, tag := range []string{"v1.2", "vnext", "v01.2.3"} {
+		if version, err := DetectVersion(configuration, testPlatform{tags: []string{tag}, branch: "master"}); err == nil {
+			t.Errorf("%s: expected error, got %s", tag, version)
+		}
+	}
+}
+
+func TestDetectHighestReleaseTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected string
+	}{
+		{"numeric order", []string{"v1.10.0", "v1.2.0", "v1.9.0"}, "1.10.0"},
+		{"release above prerelease", []string{"v2.0.0", "v2.0.0-rc.1"}, "2.0.0"},
+		{"prerelease precedence", []string{"v2.0.0-beta.3", "v2.0.0-rc.10", "v2.0.0-rc.2"}, "2.0.0-rc.10"},
+		{"other tags ignored", []string{"stable", "v1.0.0", "x2.0.0"}, "1.0.0"},
+		{"invalid tag next to a release", []string{"v1.0.0", "vnext"}, "1.0.0"},
+	}
+
+	configuration := &Configuration{Project: Project{VersionPrefix: "v"}}
+
+	for _, test := range tests {
+		version, err := DetectVersion(configuration, testPlatform{tags: test.tags, branch: "master"})
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if version.IsSnapshot() || version.SemVer() != test.expected {
+			t.Errorf("%s: expected release %s, got %s", test.name, test.expected, version)
+		}
+	}
+}
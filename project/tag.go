@@ -0,0 +1,61 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+const maxTagLength = 128
+const tagHashLength = 8
+
+var invalidTagCharacters = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// SanitizeTag turns any string into a valid Docker tag ([A-Za-z0-9_][A-Za-z0-9_.-]{0,127}).
+// Invalid characters are replaced with dashes and tags that are too long are truncated
+// and suffixed with a hash of the whole tag so they stay unique and stable across builds.
+func SanitizeTag(tag string) string {
+	sanitized := invalidTagCharacters.ReplaceAllString(tag, "-")
+
+	if sanitized == "" {
+		return "_"
+	}
+
+	if sanitized[0] == '.' || sanitized[0] == '-' {
+		sanitized = "_" + sanitized[1:]
+	}
+
+	if len(sanitized) > maxTagLength {
+		sum := sha256.Sum256([]byte(tag))
+		sanitized = sanitized[:maxTagLength-tagHashLength-1] + "-" + hex.EncodeToString(sum[:])[:tagHashLength]
+	}
+
+	return sanitized
+}
+
+// Applies tells whether the policy is used for the current branch and git tag.
+// Patterns use * as a wildcard matching any characters including slashes.
+func (p TagPolicy) Applies(branch, tag string) bool {
+	if len(p.Branches) == 0 && len(p.Tags) == 0 {
+		return true
+	}
+
+	return matchesAny(p.Branches, branch) || matchesAny(p.Tags, tag)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		expression := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+
+		if matched, err := regexp.MatchString(expression, value); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,184 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"master-snapshot", "master-snapshot"},
+		{"feature/ABC-123_x", "feature-ABC-123_x"},
+		{"1.2.3+build.5", "1.2.3-build.5"},
+		{"fix: żółw", "fix-----w"},
+		{".hidden", "_hidden"},
+		{"-dash", "_dash"},
+		{"/slash", "_slash"},
+		{"_underscore", "_underscore"},
+		{"", "_"},
+	}
+
+	for _, test := range tests {
+		if sanitized := SanitizeTag(test.tag); sanitized != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.tag, test.expected, sanitized)
+		}
+	}
+}
+
+func TestSanitizeLongTag(t *testing.T) {
+	for _, tag := range []string{
+		strings.Repeat("a", maxTagLength+1),
+		strings.Repeat("feature/", 40),
+		"." + strings.Repeat("b", 300),
+	} {
+		sanitized := SanitizeTag(tag)
+
+		if len(sanitized) != maxTagLength {
+			t.Errorf("%q: expected %d characters, got %d", tag, maxTagLength, len(sanitized))
+		}
+
+		sum := sha256.Sum256([]byte(tag))
+		suffix := "-" + hex.EncodeToString(sum[:])[:tagHashLength]
+
+		if !strings.HasSuffix(sanitized, suffix) {
+			t.Errorf("%q: expected hash suffix %s, got %s", tag, suffix, sanitized)
+		}
+
+		if SanitizeTag(tag) != sanitized {
+			t.Errorf("%q: sanitizing is not stable", tag)
+		}
+
+		if SanitizeTag(tag+"x") == sanitized {
+			t.Errorf("%q: truncated tags collide", tag)
+		}
+	}
+
+	exact := strings.Repeat("a", maxTagLength)
+
+	if sanitized := SanitizeTag(exact); sanitized != exact {
+		t.Errorf("expected tag of %d characters to be kept, got %s", maxTagLength, sanitized)
+	}
+}
+
+func TestRenderVersionTemplate(t *testing.T) {
+	data := snapshotData{
+		Branch:      "feature/login",
+		Commit:      "0123456789abcdef0123456789abcdef01234567",
+		ShortCommit: "0123456",
+		buildNumber: func() (string, error) {
+			return "42", nil
+		},
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"", "feature/login-snapshot"},
+		{"{{ .Branch }}-{{ .ShortCommit }}-{{ .BuildNumber }}", "feature/login-0123456-42"},
+		{" {{ .Commit }}\n", "0123456789abcdef0123456789abcdef01234567"},
+	}
+
+	for _, test := range tests {
+		rendered, err := renderVersionTemplate(test.template, data)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", test.template, err)
+			continue
+		}
+
+		if rendered != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.template, test.expected, rendered)
+		}
+	}
+
+	for _, template := range []string{"{{ .Branch", "{{ .Unknown }}", "{{ if false }}x{{ end }}"} {
+		if rendered, err := renderVersionTemplate(template, data); err == nil {
+			t.Errorf("%q: expected error, got %q", template, rendered)
+		}
+	}
+}
+
+func TestSnapshotVersionTag(t *testing.T) {
+	configuration := &Configuration{Project: Project{VersionTemplate: "{{ .Branch }}-{{ .BuildNumber }}"}}
+
+	tests := []struct {
+		branch string
+		dirty  bool
+		tag    string
+	}{
+		{"feature/login", false, "feature-login-42"},
+		{"feature/login", true, "feature-login-42-dirty"},
+		{".hidden", false, "_hidden-42"},
+	}
+
+	for _, test := range tests {
+		version, err := DetectVersion(configuration, testPlatform{branch: test.branch, dirty: test.dirty})
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.branch, err)
+			continue
+		}
+
+		if !version.IsSnapshot() {
+			t.Errorf("%s: expected snapshot version", test.branch)
+		}
+
+		if version.Tag() != test.tag {
+			t.Errorf("%s: expected tag %q, got %q", test.branch, test.tag, version.Tag())
+		}
+	}
+}
+
+func TestLongDirtySnapshotIsSanitizedOnce(t *testing.T) {
+	branch := strings.Repeat("feature/", 15)
+
+	version, err := DetectVersion(&Configuration{}, testPlatform{branch: branch, dirty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := branch + "-snapshot-dirty"
+	sum := sha256.Sum256([]byte(name))
+	expected := strings.Replace(name, "/", "-", -1)[:maxTagLength-tagHashLength-1] + "-" + hex.EncodeToString(sum[:])[:tagHashLength]
+	tag := version.Tag()
+
+	if tag != expected {
+		t.Errorf("expected tag truncated and hashed once %s, got %s", expected, tag)
+	}
+
+	if clean := NewSnapshotVersion(branch, branch+"-snapshot"); clean.Tag() == tag {
+		t.Errorf("expected dirty and clean snapshot tags to differ, got %s", tag)
+	}
+}
+
+func TestReleaseVersionTag(t *testing.T) {
+	configuration := &Configuration{Project: Project{VersionPrefix: "v"}}
+
+	version, err := DetectVersion(configuration, testPlatform{tags: []string{"v1.2.3-rc.1+build.5"}, dirty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version.Tag() != "1.2.3-rc.1-build.5-dirty" {
+		t.Errorf("expected tag 1.2.3-rc.1-build.5-dirty, got %s", version.Tag())
+	}
+}
+
+func TestSnapshotBuildNumberOfShallowClone(t *testing.T) {
+	platform := testPlatform{branch: "master", shallow: true}
+
+	if version, err := DetectVersion(&Configuration{}, platform); err != nil || version.Tag() != "master-snapshot" {
+		t.Errorf("expected build number not to be required by default template, got %s (%v)", version, err)
+	}
+
+	configuration := &Configuration{Project: Project{VersionTemplate: "{{ .Branch }}-{{ .BuildNumber }}"}}
+
+	if version, err := DetectVersion(configuration, platform); err == nil || !strings.Contains(err.Error(), "ShallowRepository") {
+		t.Errorf("expected ShallowRepository error, got %s (%v)", version, err)
+	}
+}
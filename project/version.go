@@ -1,27 +1,122 @@
 package project
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/wendigo/gcp-builder/platforms"
 	"strings"
+	"text/template"
 )
 
-const snapshotSuffix = "snapshot"
+const defaultVersionTemplate = "{{ .Branch }}-snapshot"
 
-func DetectVersion(project *Configuration, platform platforms.Platform) (string, error) {
+type snapshotData struct {
+	Branch      string
+	Commit      string
+	ShortCommit string
+	buildNumber func() (string, error)
+}
+
+// BuildNumber is resolved only when the version template uses it, as not every platform
+// can provide it (shallow clones, for example).
+func (d snapshotData) BuildNumber() (string, error) {
+	return d.buildNumber()
+}
+
+func DetectVersion(project *Configuration, platform platforms.Platform) (Version, error) {
+	version, err := detectVersion(project, platform)
+	if err != nil {
+		return Version{}, err
+	}
+
+	version.Dirty = platform.IsDirty()
+
+	return version, nil
+}
 
-	if strings.HasPrefix(platform.CurrentTag(), project.Project.VersionPrefix) {
-		return strings.TrimPrefix(platform.CurrentTag(), project.Project.VersionPrefix), nil
+func detectVersion(project *Configuration, platform platforms.Platform) (Version, error) {
+	version, released, err := releaseVersion(project.Project.VersionPrefix, platform.CurrentTags())
+	if err != nil || released {
+		return version, err
 	}
 
 	if platform.CurrentCommit() == "" {
-		return "", errors.New("CouldNotResolveVersion")
+		return Version{}, errors.New("CouldNotResolveVersion")
 	}
 
-	return fmt.Sprintf("%s-%s", platform.CurrentBranch(), snapshotSuffix), nil
+	name, err := renderVersionTemplate(project.Project.VersionTemplate, snapshotData{
+		Branch:      platform.CurrentBranch(),
+		Commit:      platform.CurrentCommit(),
+		ShortCommit: platforms.Commit{Hash: platform.CurrentCommit()}.ShortHash(),
+		buildNumber: platform.CurrentBuildNumber,
+	})
+	if err != nil {
+		return Version{}, err
+	}
+
+	return NewSnapshotVersion(platform.CurrentBranch(), name), nil
 }
 
-func IsSnapshotVersion(version string) bool {
-	return strings.HasSuffix(version, snapshotSuffix)
+// releaseVersion returns the highest version of tags with the version prefix. Invalid tags
+// are an error unless another tag on the same commit is a valid release.
+func releaseVersion(prefix string, tags []string) (Version, bool, error) {
+	var highest *Version
+	invalid := ""
+
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+
+		version, err := ParseVersion(strings.TrimPrefix(tag, prefix))
+		if err != nil {
+			if invalid == "" {
+				invalid = tag
+			}
+
+			continue
+		}
+
+		if highest == nil || version.Compare(*highest) > 0 {
+			highest = &version
+		}
+	}
+
+	if highest != nil {
+		return *highest, true, nil
+	}
+
+	if invalid != "" {
+		return Version{}, false, errors.New(fmt.Sprintf(
+			"InvalidReleaseTag(%s): expected %sMAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]",
+			invalid,
+			prefix,
+		))
+	}
+
+	return Version{}, false, nil
+}
+
+func renderVersionTemplate(versionTemplate string, data snapshotData) (string, error) {
+	if versionTemplate == "" {
+		versionTemplate = defaultVersionTemplate
+	}
+
+	tmpl, err := template.New("version").Parse(versionTemplate)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("InvalidVersionTemplate(%s): %s", versionTemplate, err))
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", errors.New(fmt.Sprintf("InvalidVersionTemplate(%s): %s", versionTemplate, err))
+	}
+
+	if strings.TrimSpace(buffer.String()) == "" {
+		return "", errors.New(fmt.Sprintf("EmptyVersion(%s)", versionTemplate))
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
 }
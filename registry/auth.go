@@ -0,0 +1,184 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Credentials of a registry. IdentityToken is an OAuth2 refresh token.
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+func (c Credentials) empty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// CredentialsFunc resolves credentials of a registry host, empty credentials mean anonymous access.
+type CredentialsFunc func(host string) (Credentials, error)
+
+type challenge struct {
+	scheme     string
+	parameters map[string]string
+}
+
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// parseChallenge parses WWW-Authenticate: Bearer realm="...",service="...",scope="...".
+func parseChallenge(header string) (challenge, error) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if parts[0] == "" {
+		return challenge{}, errors.New(fmt.Sprintf("InvalidAuthChallenge(%s)", header))
+	}
+
+	parsed := challenge{scheme: strings.ToLower(parts[0]), parameters: make(map[string]string)}
+
+	if len(parts) == 1 {
+		return parsed, nil
+	}
+
+	rest := parts[1]
+
+	for rest != "" {
+		equals := strings.Index(rest, "=")
+		if equals == -1 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(rest[:equals]))
+		rest = strings.TrimSpace(rest[equals+1:])
+
+		var value string
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				return challenge{}, errors.New(fmt.Sprintf("InvalidAuthChallenge(%s)", header))
+			}
+
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.Index(rest, ","); comma != -1 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+
+		parsed.parameters[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+
+	return parsed, nil
+}
+
+// authorization answers the challenge returning the Authorization header value.
+func (c *Client) authorization(header string, host string, scope string) (string, error) {
+	parsed, err := parseChallenge(header)
+	if err != nil {
+		return "", err
+	}
+
+	credentials, err := c.credentials(host)
+	if err != nil {
+		return "", err
+	}
+
+	switch parsed.scheme {
+	case "basic":
+		if credentials.Username == "" {
+			return "", errors.New(fmt.Sprintf("Unauthorized(%s): registry requires credentials", host))
+		}
+
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+
+		return request.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := c.token(parsed.parameters, scope, credentials)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Bearer %s", token), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("UnsupportedAuthScheme(%s)", parsed.scheme))
+}
+
+// token fetches a bearer token from the realm. Refresh tokens are exchanged with the OAuth2
+// POST flow, username and password with basic authentication.
+func (c *Client) token(parameters map[string]string, scope string, credentials Credentials) (string, error) {
+	realm := parameters["realm"]
+	if realm == "" {
+		return "", errors.New("InvalidAuthChallenge: missing realm")
+	}
+
+	if parameters["scope"] != "" && !strings.Contains(scope, parameters["scope"]) {
+		scope = parameters["scope"]
+	}
+
+	var request *http.Request
+	var err error
+
+	if credentials.IdentityToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", credentials.IdentityToken)
+		form.Set("service", parameters["service"])
+		form.Set("scope", scope)
+		form.Set("client_id", "gcp-builder")
+
+		request, err = http.NewRequest("POST", realm, strings.NewReader(form.Encode()))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		query := url.Values{}
+		query.Set("service", parameters["service"])
+		query.Set("scope", scope)
+
+		request, err = http.NewRequest("GET", fmt.Sprintf("%s?%s", realm, query.Encode()), nil)
+		if err == nil && !credentials.empty() {
+			request.SetBasicAuth(credentials.Username, credentials.Password)
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New(fmt.Sprintf("Unauthorized(%s): token request failed with %s", realm, response.Status))
+	}
+
+	token := tokenResponse{}
+
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	if token.Token != "" {
+		return token.Token, nil
+	}
+
+	if token.AccessToken != "" {
+		return token.AccessToken, nil
+	}
+
+	return "", errors.New(fmt.Sprintf("Unauthorized(%s): empty token", realm))
+}
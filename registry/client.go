@@ -0,0 +1,377 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOciManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOciIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestMediaTypes = []string{
+	MediaTypeDockerManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeOciManifest,
+	MediaTypeOciIndex,
+}
+
+// Client talks to the registry v2 API answering Bearer and Basic authentication challenges.
+// Registries on localhost are accessed over plain HTTP like the Docker daemon does.
+type Client struct {
+	http           *http.Client
+	credentials    CredentialsFunc
+	authorizations map[string]string
+	logger         *log.Logger
+}
+
+type Manifest struct {
+	Digest    string
+	MediaType string
+	Body      []byte
+}
+
+type manifestBody struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			Os           string `json:"os"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+type imageConfig struct {
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+type notFoundError struct {
+	error
+}
+
+func IsNotFound(err error) bool {
+	_, ok := err.(notFoundError)
+	return ok
+}
+
+func New(credentials CredentialsFunc) *Client {
+	return &Client{
+		http:           &http.Client{Timeout: 60 * time.Second},
+		credentials:    credentials,
+		authorizations: make(map[string]string),
+		logger: log.New(
+			os.Stdout, "[registry] ", log.Lmicroseconds,
+		),
+	}
+}
+
+// IsIndex tells whether the manifest is a manifest list or an OCI index.
+func (m Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeDockerManifestList || m.MediaType == MediaTypeOciIndex
+}
+
+// ConfigDigest returns the digest of the image config, empty for manifest lists.
+func (m Manifest) ConfigDigest() string {
+	body := manifestBody{}
+
+	if err := json.Unmarshal(m.Body, &body); err != nil {
+		return ""
+	}
+
+	return body.Config.Digest
+}
+
+// Platforms maps os/architecture[/variant] to manifest digests of a manifest list.
+func (m Manifest) Platforms() map[string]string {
+	platforms := make(map[string]string)
+	body := manifestBody{}
+
+	if err := json.Unmarshal(m.Body, &body); err != nil {
+		return platforms
+	}
+
+	for _, manifest := range body.Manifests {
+		if manifest.Platform.Os == "unknown" {
+			continue
+		}
+
+		platform := fmt.Sprintf("%s/%s", manifest.Platform.Os, manifest.Platform.Architecture)
+
+		if manifest.Platform.Variant != "" {
+			platform = fmt.Sprintf("%s/%s", platform, manifest.Platform.Variant)
+		}
+
+		platforms[platform] = manifest.Digest
+	}
+
+	return platforms
+}
+
+// Digest resolves the digest of a tag with a manifest HEAD request. For multi-arch images
+// it is the digest of the manifest list.
+func (c *Client) Digest(reference string) (string, error) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.do("HEAD", ref, fmt.Sprintf("manifests/%s", ref.Reference), nil, map[string]string{"Accept": strings.Join(manifestMediaTypes, ", ")}, false)
+	if err != nil {
+		return "", err
+	}
+
+	response.Body.Close()
+
+	if err := checkResponse(reference, response, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// the header is optional so fall back to hashing the manifest
+	manifest, err := c.Manifest(reference)
+	if err != nil {
+		return "", err
+	}
+
+	return manifest.Digest, nil
+}
+
+// Manifest fetches the manifest as stored in the registry.
+func (c *Client) Manifest(reference string) (*Manifest, error) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.do("GET", ref, fmt.Sprintf("manifests/%s", ref.Reference), nil, map[string]string{"Accept": strings.Join(manifestMediaTypes, ", ")}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if err := checkResponse(reference, response, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := response.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	return &Manifest{
+		Digest:    digest,
+		MediaType: strings.TrimSpace(strings.Split(response.Header.Get("Content-Type"), ";")[0]),
+		Body:      body,
+	}, nil
+}
+
+// PutManifest stores the manifest under the tag of the reference and returns its digest.
+func (c *Client) PutManifest(reference string, manifest *Manifest) (string, error) {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := c.do("PUT", ref, fmt.Sprintf("manifests/%s", ref.Reference), manifest.Body, map[string]string{"Content-Type": manifest.MediaType}, true)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	if err := checkResponse(reference, response, http.StatusCreated, http.StatusOK); err != nil {
+		return "", err
+	}
+
+	if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	return manifest.Digest, nil
+}
+
+// DeleteTag removes the tag of the reference. The manifest is never deleted by its digest as
+// other tags may point to it, registries which can't delete tags answer with an error.
+func (c *Client) DeleteTag(reference string) error {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(ref.Reference, "sha256:") {
+		return errors.New(fmt.Sprintf("InvalidTag(%s): only tags can be deleted", reference))
+	}
+
+	response, err := c.do("DELETE", ref, fmt.Sprintf("manifests/%s", ref.Reference), nil, nil, true)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	return checkResponse(reference, response, http.StatusAccepted, http.StatusOK)
+}
+
+// Labels returns the digest and config labels of an image. Manifest lists return labels of
+// their first platform image.
+func (c *Client) Labels(reference string) (string, map[string]string, error) {
+	manifest, err := c.Manifest(reference)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if manifest.IsIndex() {
+		body := manifestBody{}
+
+		if err := json.Unmarshal(manifest.Body, &body); err != nil {
+			return "", nil, err
+		}
+
+		for _, platform := range body.Manifests {
+			// attestation manifests of buildx are stored as unknown/unknown
+			if platform.Platform.Os == "unknown" {
+				continue
+			}
+
+			_, labels, err := c.Labels(ref.WithReference(platform.Digest).String())
+
+			return manifest.Digest, labels, err
+		}
+
+		return manifest.Digest, nil, nil
+	}
+
+	configDigest := manifest.ConfigDigest()
+	if configDigest == "" {
+		return manifest.Digest, nil, nil
+	}
+
+	response, err := c.do("GET", ref, fmt.Sprintf("blobs/%s", configDigest), nil, nil, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defer response.Body.Close()
+
+	if err := checkResponse(reference, response, http.StatusOK); err != nil {
+		return "", nil, err
+	}
+
+	config := imageConfig{}
+
+	if err := json.NewDecoder(response.Body).Decode(&config); err != nil {
+		return "", nil, err
+	}
+
+	return manifest.Digest, config.Config.Labels, nil
+}
+
+// do sends the request authorizing it when the registry answers with a challenge.
+// Authorizations are cached per host and scope.
+func (c *Client) do(method string, ref Reference, path string, body []byte, headers map[string]string, push bool) (*http.Response, error) {
+	scope := fmt.Sprintf("repository:%s:pull", ref.Repository)
+	if push {
+		scope = fmt.Sprintf("repository:%s:pull,push", ref.Repository)
+	}
+
+	if method == "DELETE" {
+		scope += ",delete"
+	}
+
+	key := fmt.Sprintf("%s %s", ref.Host, scope)
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequest(method, fmt.Sprintf("%s/v2/%s/%s", endpoint(ref.Host), ref.Repository, path), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+
+		if authorization, exists := c.authorizations[key]; exists {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		response, err := c.http.Do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		challenge := response.Header.Get("WWW-Authenticate")
+
+		if response.StatusCode != http.StatusUnauthorized || challenge == "" || attempt > 0 {
+			return response, nil
+		}
+
+		response.Body.Close()
+
+		authorization, err := c.authorization(challenge, ref.Host, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		c.authorizations[key] = authorization
+	}
+}
+
+func endpoint(host string) string {
+	if host == DockerHub {
+		return fmt.Sprintf("https://%s", dockerHubApi)
+	}
+
+	if host == "localhost" || strings.HasPrefix(host, "localhost:") || strings.HasPrefix(host, "127.0.0.1") || strings.HasPrefix(host, "[::1]") {
+		return fmt.Sprintf("http://%s", host)
+	}
+
+	return fmt.Sprintf("https://%s", host)
+}
+
+func checkResponse(reference string, response *http.Response, expected ...int) error {
+	for _, status := range expected {
+		if response.StatusCode == status {
+			return nil
+		}
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return notFoundError{errors.New(fmt.Sprintf("ImageNotFound(%s)", reference))}
+	}
+
+	return errors.New(fmt.Sprintf("RegistryError(%s): %s", reference, response.Status))
+}
@@ -0,0 +1,265 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "secret-token"
+
+var testManifest = []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"digest":"sha256:config"}}`)
+
+var testManifestList = []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[` +
+	`{"digest":"sha256:amd64","platform":{"os":"linux","architecture":"amd64"}},` +
+	`{"digest":"sha256:arm64","platform":{"os":"linux","architecture":"arm64","variant":"v8"}},` +
+	`{"digest":"sha256:attestation","platform":{"os":"unknown","architecture":"unknown"}}]}`)
+
+// testRegistry is a registry stand-in serving manifests of a single repository. Tags listed
+// in withoutDigestHeader are served without the optional Docker-Content-Digest header.
+type testRegistry struct {
+	*httptest.Server
+	bearer              bool
+	withoutDigestHeader map[string]bool
+	tokenRequests       int
+	requests            []string
+}
+
+type testManifestEntry struct {
+	mediaType string
+	body      []byte
+}
+
+var testManifests = map[string]testManifestEntry{
+	"1.0.0":  {MediaTypeDockerManifest, testManifest},
+	"legacy": {MediaTypeDockerManifest, testManifest},
+	"multi":  {MediaTypeDockerManifestList, testManifestList},
+}
+
+func newTestRegistry(bearer bool) *testRegistry {
+	registry := &testRegistry{bearer: bearer, withoutDigestHeader: map[string]bool{"legacy": true}}
+	registry.Server = httptest.NewServer(http.HandlerFunc(registry.serve))
+
+	return registry
+}
+
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, request *http.Request) {
+	r.requests = append(r.requests, fmt.Sprintf("%s %s", request.Method, request.URL.Path))
+
+	if request.URL.Path == "/token" {
+		r.tokenRequests++
+
+		username, password, ok := request.BasicAuth()
+		if !ok || username != "user" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if request.URL.Query().Get("scope") != "repository:team/app:pull" || request.URL.Query().Get("service") != "test-registry" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, `{"token":"%s"}`, testToken)
+		return
+	}
+
+	if r.bearer && request.Header.Get("Authorization") != "Bearer "+testToken {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry",scope="repository:team/app:pull"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tag := strings.TrimPrefix(request.URL.Path, "/v2/team/app/manifests/")
+	manifest, exists := testManifests[tag]
+
+	if request.Method == "DELETE" && exists {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if !exists || !strings.Contains(request.Header.Get("Accept"), manifest.mediaType) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", manifest.mediaType)
+
+	if !r.withoutDigestHeader[tag] {
+		w.Header().Set("Docker-Content-Digest", digestOf(manifest.body))
+	}
+
+	if request.Method == "GET" {
+		w.Write(manifest.body)
+	}
+}
+
+func digestOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func anonymous(host string) (Credentials, error) {
+	return Credentials{}, nil
+}
+
+func password(host string) (Credentials, error) {
+	return Credentials{Username: "user", Password: "password"}, nil
+}
+
+func TestDigestFromHeader(t *testing.T) {
+	registry := newTestRegistry(false)
+	defer registry.Close()
+
+	digest, err := New(anonymous).Digest(registry.host() + "/team/app:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest != digestOf(testManifest) {
+		t.Errorf("expected digest %s, got %s", digestOf(testManifest), digest)
+	}
+
+	if len(registry.requests) != 1 || registry.requests[0] != "HEAD /v2/team/app/manifests/1.0.0" {
+		t.Errorf("expected a single HEAD request, got %v", registry.requests)
+	}
+}
+
+func TestDigestWithoutHeaderHashesManifest(t *testing.T) {
+	registry := newTestRegistry(false)
+	defer registry.Close()
+
+	digest, err := New(anonymous).Digest(registry.host() + "/team/app:legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest != digestOf(testManifest) {
+		t.Errorf("expected digest %s, got %s", digestOf(testManifest), digest)
+	}
+
+	expected := []string{"HEAD /v2/team/app/manifests/legacy", "GET /v2/team/app/manifests/legacy"}
+
+	if strings.Join(registry.requests, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected requests %v, got %v", expected, registry.requests)
+	}
+}
+
+func TestDigestWithBearerChallenge(t *testing.T) {
+	registry := newTestRegistry(true)
+	defer registry.Close()
+
+	client := New(password)
+
+	for _, tag := range []string{"1.0.0", "multi"} {
+		if _, err := client.Digest(registry.host() + "/team/app:" + tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if registry.tokenRequests != 1 {
+		t.Errorf("expected token to be requested once and cached, got %d requests", registry.tokenRequests)
+	}
+
+	expected := []string{
+		"HEAD /v2/team/app/manifests/1.0.0",
+		"GET /token",
+		"HEAD /v2/team/app/manifests/1.0.0",
+		"HEAD /v2/team/app/manifests/multi",
+	}
+
+	if strings.Join(registry.requests, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected requests %v, got %v", expected, registry.requests)
+	}
+}
+
+func TestDigestWithRejectedCredentials(t *testing.T) {
+	registry := newTestRegistry(true)
+	defer registry.Close()
+
+	_, err := New(anonymous).Digest(registry.host() + "/team/app:1.0.0")
+
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("expected Unauthorized error, got %v", err)
+	}
+}
+
+func TestDigestNotFound(t *testing.T) {
+	registry := newTestRegistry(true)
+	defer registry.Close()
+
+	_, err := New(password).Digest(registry.host() + "/team/app:missing")
+
+	if !IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	if IsNotFound(fmt.Errorf("RegistryError")) {
+		t.Error("expected other errors not to be reported as not found")
+	}
+}
+
+func TestDigestOfManifestList(t *testing.T) {
+	registry := newTestRegistry(false)
+	defer registry.Close()
+
+	client := New(anonymous)
+	reference := registry.host() + "/team/app:multi"
+
+	digest, err := client.Digest(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest != digestOf(testManifestList) {
+		t.Errorf("expected manifest list digest %s, got %s", digestOf(testManifestList), digest)
+	}
+
+	manifest, err := client.Manifest(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !manifest.IsIndex() || manifest.Digest != digest {
+		t.Errorf("expected manifest list with digest %s, got %s (%s)", digest, manifest.Digest, manifest.MediaType)
+	}
+
+	platforms := manifest.Platforms()
+
+	if len(platforms) != 2 || platforms["linux/amd64"] != "sha256:amd64" || platforms["linux/arm64/v8"] != "sha256:arm64" {
+		t.Errorf("unexpected platforms %v", platforms)
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	registry := newTestRegistry(false)
+	defer registry.Close()
+
+	client := New(anonymous)
+
+	if err := client.DeleteTag(registry.host() + "/team/app:1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteTag(registry.host() + "/team/app:missing"); !IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	if err := client.DeleteTag(registry.host() + "/team/app@" + digestOf(testManifest)); err == nil || !strings.Contains(err.Error(), "InvalidTag") {
+		t.Errorf("expected InvalidTag for a digest, got %v", err)
+	}
+
+	expected := []string{"DELETE /v2/team/app/manifests/1.0.0", "DELETE /v2/team/app/manifests/missing"}
+
+	if strings.Join(registry.requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected requests %v, got %v", expected, registry.requests)
+	}
+}
@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Descriptor references a platform image from a manifest list.
+type Descriptor struct {
+	MediaType string
+	Digest    string
+	Size      int
+	// Platform is os/architecture[/variant]
+	Platform string
+}
+
+type index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []indexEntry `json:"manifests"`
+}
+
+type indexEntry struct {
+	MediaType string        `json:"mediaType"`
+	Digest    string        `json:"digest"`
+	Size      int           `json:"size"`
+	Platform  indexPlatform `json:"platform"`
+}
+
+type indexPlatform struct {
+	Architecture string `json:"architecture"`
+	Os           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// NewIndex builds an OCI index when all platform images are OCI manifests and a Docker
+// manifest list otherwise.
+func NewIndex(descriptors []Descriptor) (*Manifest, error) {
+	body := index{SchemaVersion: 2, MediaType: MediaTypeOciIndex, Manifests: make([]indexEntry, 0)}
+
+	for _, descriptor := range descriptors {
+		parts := strings.Split(descriptor.Platform, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New(fmt.Sprintf("InvalidPlatform(%s): expected os/architecture[/variant]", descriptor.Platform))
+		}
+
+		platform := indexPlatform{Os: parts[0], Architecture: parts[1]}
+		if len(parts) == 3 {
+			platform.Variant = parts[2]
+		}
+
+		if descriptor.MediaType != MediaTypeOciManifest {
+			body.MediaType = MediaTypeDockerManifestList
+		}
+
+		body.Manifests = append(body.Manifests, indexEntry{
+			MediaType: descriptor.MediaType,
+			Digest:    descriptor.Digest,
+			Size:      descriptor.Size,
+			Platform:  platform,
+		})
+	}
+
+	contents, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(contents)
+
+	return &Manifest{
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		MediaType: body.MediaType,
+		Body:      contents,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reference is registry/repository followed by :tag or @digest.
+type Reference struct {
+	Host       string
+	Repository string
+	// Reference is the tag or digest
+	Reference string
+}
+
+func ParseReference(reference string) (Reference, error) {
+	slash := strings.Index(reference, "/")
+	if slash == -1 {
+		return Reference{}, errors.New(fmt.Sprintf("InvalidImageReference(%s)", reference))
+	}
+
+	parsed := Reference{Host: reference[:slash]}
+	rest := reference[slash+1:]
+
+	if at := strings.Index(rest, "@"); at != -1 {
+		parsed.Repository, parsed.Reference = rest[:at], rest[at+1:]
+	} else if colon := strings.LastIndex(rest, ":"); colon > strings.LastIndex(rest, "/") {
+		parsed.Repository, parsed.Reference = rest[:colon], rest[colon+1:]
+	} else {
+		parsed.Repository, parsed.Reference = rest, "latest"
+	}
+
+	if parsed.Repository == "" || parsed.Reference == "" {
+		return Reference{}, errors.New(fmt.Sprintf("InvalidImageReference(%s)", reference))
+	}
+
+	return parsed, nil
+}
+
+func (r Reference) String() string {
+	if strings.Contains(r.Reference, ":") {
+		return fmt.Sprintf("%s/%s@%s", r.Host, r.Repository, r.Reference)
+	}
+
+	return fmt.Sprintf("%s/%s:%s", r.Host, r.Repository, r.Reference)
+}
+
+// WithReference returns the reference pointing to another tag or digest of the repository.
+func (r Reference) WithReference(reference string) Reference {
+	r.Reference = reference
+	return r
+}
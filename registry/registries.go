@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	KindContainerRegistry = "container-registry"
+	KindArtifactRegistry  = "artifact-registry"
+	KindDockerHub         = "docker-hub"
+	KindGeneric           = "generic"
+)
+
+const DockerHub = "docker.io"
+
+// dockerHubApi serves the registry v2 API of Docker Hub.
+const dockerHubApi = "registry-1.docker.io"
+
+// Host returns the registry host of an image reference or registry url, Docker Hub for
+// references without one.
+func Host(reference string) string {
+	first, qualified := firstComponent(reference)
+	if !qualified || first == "index.docker.io" || first == dockerHubApi {
+		return DockerHub
+	}
+
+	return first
+}
+
+// Qualify prefixes registry urls without a host with docker.io.
+func Qualify(url string) string {
+	url = strings.TrimSuffix(url, "/")
+
+	if _, qualified := firstComponent(url); qualified || url == "" {
+		return url
+	}
+
+	return fmt.Sprintf("%s/%s", DockerHub, url)
+}
+
+// firstComponent tells whether the first path component is a host: it contains a dot or
+// a colon or is localhost.
+func firstComponent(reference string) (string, bool) {
+	first := reference
+	if slash := strings.Index(reference, "/"); slash != -1 {
+		first = reference[:slash]
+	}
+
+	return first, strings.ContainsAny(first, ".:") || first == "localhost"
+}
+
+func KindOf(host string) string {
+	switch {
+	case host == "gcr.io" || strings.HasSuffix(host, ".gcr.io"):
+		return KindContainerRegistry
+	case strings.HasSuffix(host, "-docker.pkg.dev"):
+		return KindArtifactRegistry
+	case host == DockerHub:
+		return KindDockerHub
+	}
+
+	return KindGeneric
+}
+
+// IsGoogle tells whether the host is Container Registry or Artifact Registry accepting
+// gcloud access tokens.
+func IsGoogle(host string) bool {
+	kind := KindOf(host)
+	return kind == KindContainerRegistry || kind == KindArtifactRegistry
+}
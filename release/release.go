@@ -0,0 +1,159 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/platforms"
+	"github.com/wendigo/gcp-builder/project"
+	"regexp"
+	"strings"
+)
+
+const BumpMajor = "major"
+const BumpMinor = "minor"
+const BumpPatch = "patch"
+
+// <type>[(scope)][!]: <description>, see https://www.conventionalcommits.org
+var conventionalSubject = regexp.MustCompile(`^(\w+)(?:\([^)]*\))?(!)?:`)
+var breakingChange = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:`)
+
+type Repository interface {
+	CurrentCommit() string
+	Tags() ([]platforms.Tag, error)
+	CommitsSince(since string) ([]platforms.Commit, error)
+}
+
+type Release struct {
+	PreviousTag string
+	Previous    project.Version
+	Next        project.Version
+	Tag         string
+	Bump        string
+	Commits     []platforms.Commit
+}
+
+// Prepare computes the next release from the latest tag starting with prefix and commits
+// made since then. Bump is detected from conventional commit messages unless overridden.
+func Prepare(prefix string, repository Repository, bump string) (*Release, error) {
+	if bump != "" && bump != BumpMajor && bump != BumpMinor && bump != BumpPatch {
+		return nil, errors.New(fmt.Sprintf("InvalidBump(%s)", bump))
+	}
+
+	tags, err := repository.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{}
+	previousCommit := ""
+
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag.Name, prefix) {
+			continue
+		}
+
+		version, err := project.ParseVersion(strings.TrimPrefix(tag.Name, prefix))
+		if err != nil {
+			continue
+		}
+
+		if release.PreviousTag == "" || release.Previous.LessThan(version) {
+			release.PreviousTag = tag.Name
+			release.Previous = version
+			previousCommit = tag.Commit
+		}
+	}
+
+	if previousCommit != "" && previousCommit == repository.CurrentCommit() {
+		return nil, errors.New(fmt.Sprintf("AlreadyReleased(%s)", release.PreviousTag))
+	}
+
+	release.Commits, err = repository.CommitsSince(previousCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(release.Commits) == 0 {
+		return nil, errors.New(fmt.Sprintf("NothingToRelease(%s)", release.PreviousTag))
+	}
+
+	if bump == "" {
+		bump = DetectBump(release.Commits)
+	}
+
+	release.Bump = bump
+	release.Next = Bump(release.Previous, bump)
+	release.Tag = prefix + release.Next.SemVer()
+
+	for _, tag := range tags {
+		if tag.Name == release.Tag {
+			return nil, errors.New(fmt.Sprintf("TagAlreadyExists(%s)", release.Tag))
+		}
+	}
+
+	return release, nil
+}
+
+// DetectBump returns the highest bump required by conventional commit messages: breaking
+// changes bump major, features bump minor and everything else bumps patch.
+func DetectBump(commits []platforms.Commit) string {
+	bump := BumpPatch
+
+	for _, commit := range commits {
+		matches := conventionalSubject.FindStringSubmatch(commit.Subject)
+
+		if (matches != nil && matches[2] == "!") || breakingChange.MatchString(commit.Message) {
+			return BumpMajor
+		}
+
+		if matches != nil && matches[1] == "feat" {
+			bump = BumpMinor
+		}
+	}
+
+	return bump
+}
+
+// Bump increments the version. Pre-releases are promoted to the release they precede when
+// it satisfies the bump, so 1.2.0-rc.1 bumped by minor becomes 1.2.0.
+func Bump(version project.Version, bump string) project.Version {
+	next := project.Version{
+		Major: version.Major,
+		Minor: version.Minor,
+		Patch: version.Patch,
+	}
+
+	prerelease := version.Prerelease != ""
+
+	switch bump {
+	case BumpMajor:
+		if !prerelease || version.Minor != 0 || version.Patch != 0 {
+			next.Major++
+		}
+
+		next.Minor = 0
+		next.Patch = 0
+	case BumpMinor:
+		if !prerelease || version.Patch != 0 {
+			next.Minor++
+		}
+
+		next.Patch = 0
+	default:
+		if !prerelease {
+			next.Patch++
+		}
+	}
+
+	return next
+}
+
+func (r *Release) Message() string {
+	lines := []string{fmt.Sprintf("Release %s", r.Tag), ""}
+
+	for _, commit := range r.Commits {
+		lines = append(lines, fmt.Sprintf("* %s %s", commit.ShortHash(), commit.Subject))
+	}
+
+	return strings.Join(lines, "\n")
+}
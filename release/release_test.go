@@ -0,0 +1,164 @@
+package release
+
+import (
+	"github.com/wendigo/gcp-builder/platforms"
+	"github.com/wendigo/gcp-builder/project"
+	"strings"
+	"testing"
+)
+
+type testRepository struct {
+	head    string
+	tags    []platforms.Tag
+	commits []platforms.Commit
+}
+
+func (r testRepository) CurrentCommit() string {
+	return r.head
+}
+
+func (r testRepository) Tags() ([]platforms.Tag, error) {
+	return r.tags, nil
+}
+
+func (r testRepository) CommitsSince(since string) ([]platforms.Commit, error) {
+	return r.commits, nil
+}
+
+func commit(message string) platforms.Commit {
+	return platforms.Commit{Hash: "0123456789abcdef", Subject: strings.SplitN(message, "\n", 2)[0], Message: message}
+}
+
+func TestDetectBump(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		expected string
+	}{
+		{"fix", []string{"fix: typo"}, BumpPatch},
+		{"other types", []string{"chore: deps", "docs(readme): usage", "refactor: names"}, BumpPatch},
+		{"non conventional", []string{"Update things"}, BumpPatch},
+		{"feature", []string{"fix: typo", "feat: login"}, BumpMinor},
+		{"scoped feature", []string{"feat(api): login"}, BumpMinor},
+		{"feature in body only", []string{"fix: typo\n\nfeat: not a subject"}, BumpPatch},
+		{"breaking marker", []string{"feat: login", "refactor!: drop v1"}, BumpMajor},
+		{"scoped breaking marker", []string{"feat(api)!: drop v1"}, BumpMajor},
+		{"breaking change footer", []string{"fix: typo\n\nBREAKING CHANGE: config renamed"}, BumpMajor},
+		{"breaking-change footer", []string{"chore: x\n\nBREAKING-CHANGE: config renamed"}, BumpMajor},
+		{"breaking change mentioned mid line", []string{"fix: x\n\nno BREAKING CHANGE: here"}, BumpPatch},
+		{"uppercase type", []string{"Feat: login"}, BumpPatch},
+	}
+
+	for _, test := range tests {
+		commits := make([]platforms.Commit, 0)
+
+		for _, message := range test.messages {
+			commits = append(commits, commit(message))
+		}
+
+		if bump := DetectBump(commits); bump != test.expected {
+			t.Errorf("%s: expected %s bump, got %s", test.name, test.expected, bump)
+		}
+	}
+}
+
+func TestBump(t *testing.T) {
+	tests := []struct {
+		version  string
+		bump     string
+		expected string
+	}{
+		{"0.0.0", BumpPatch, "0.0.1"},
+		{"1.2.3", BumpPatch, "1.2.4"},
+		{"1.2.3", BumpMinor, "1.3.0"},
+		{"1.2.3", BumpMajor, "2.0.0"},
+		{"1.2.3+build.1", BumpPatch, "1.2.4"},
+		{"1.2.3-rc.1", BumpPatch, "1.2.3"},
+		{"1.2.3-rc.1", BumpMinor, "1.3.0"},
+		{"1.2.3-rc.1", BumpMajor, "2.0.0"},
+		{"1.2.0-rc.1", BumpPatch, "1.2.0"},
+		{"1.2.0-rc.1", BumpMinor, "1.2.0"},
+		{"1.2.0-rc.1", BumpMajor, "2.0.0"},
+		{"2.0.0-beta.2", BumpPatch, "2.0.0"},
+		{"2.0.0-beta.2", BumpMinor, "2.0.0"},
+		{"2.0.0-beta.2", BumpMajor, "2.0.0"},
+	}
+
+	for _, test := range tests {
+		version, err := project.ParseVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next := Bump(version, test.bump).SemVer(); next != test.expected {
+			t.Errorf("%s bumped by %s: expected %s, got %s", test.version, test.bump, test.expected, next)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	tags := []platforms.Tag{
+		{Name: "v1.2.0", Commit: "a"},
+		{Name: "v1.10.0", Commit: "b"},
+		{Name: "v1.9.0", Commit: "c"},
+		{Name: "vnext", Commit: "d"},
+		{Name: "2.0.0", Commit: "e"},
+	}
+
+	tests := []struct {
+		name     string
+		tags     []platforms.Tag
+		messages []string
+		bump     string
+		previous string
+		expected string
+		detected string
+	}{
+		{"first release", nil, []string{"feat: init"}, "", "", "v0.1.0", BumpMinor},
+		{"highest previous version", tags, []string{"fix: typo"}, "", "v1.10.0", "v1.10.1", BumpPatch},
+		{"detected minor", tags, []string{"fix: typo", "feat: login"}, "", "v1.10.0", "v1.11.0", BumpMinor},
+		{"detected major", tags, []string{"fix!: drop flag"}, "", "v1.10.0", "v2.0.0", BumpMajor},
+		{"overridden bump", tags, []string{"feat!: drop flag"}, BumpPatch, "v1.10.0", "v1.10.1", BumpPatch},
+		{"promoted prerelease", []platforms.Tag{{Name: "v3.0.0-rc.2", Commit: "a"}}, []string{"fix: typo"}, "", "v3.0.0-rc.2", "v3.0.0", BumpPatch},
+	}
+
+	for _, test := range tests {
+		commits := make([]platforms.Commit, 0)
+
+		for _, message := range test.messages {
+			commits = append(commits, commit(message))
+		}
+
+		release, err := Prepare("v", testRepository{head: "head", tags: test.tags, commits: commits}, test.bump)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if release.PreviousTag != test.previous || release.Tag != test.expected || release.Bump != test.detected {
+			t.Errorf("%s: expected %s -> %s (%s), got %s -> %s (%s)",
+				test.name, test.previous, test.expected, test.detected, release.PreviousTag, release.Tag, release.Bump)
+		}
+	}
+}
+
+func TestPrepareErrors(t *testing.T) {
+	tags := []platforms.Tag{{Name: "v1.0.0", Commit: "released"}, {Name: "v1.0.1", Commit: "other"}}
+	commits := []platforms.Commit{commit("fix: typo")}
+
+	tests := []struct {
+		name       string
+		repository testRepository
+		bump       string
+	}{
+		{"invalid bump", testRepository{head: "head", tags: tags, commits: commits}, "huge"},
+		{"already released", testRepository{head: "other", tags: tags, commits: commits}, ""},
+		{"nothing to release", testRepository{head: "head", tags: tags}, ""},
+	}
+
+	for _, test := range tests {
+		if release, err := Prepare("v", test.repository, test.bump); err == nil {
+			t.Errorf("%s: expected error, got %s", test.name, release.Tag)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// Report summarizes a run and is written as JSON once all steps have finished.
+type Report struct {
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	Version     string    `json:"version"`
+	Commit      string    `json:"commit"`
+	Steps       []string  `json:"steps"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+	Error       string    `json:"error,omitempty"`
+	Images      []*Image  `json:"images"`
+}
+
+type Image struct {
+	Name    string `json:"name"`
+	Tag     string `json:"tag"`
+	Digest  string `json:"digest,omitempty"`
+	Builder string `json:"builder,omitempty"`
+	Cache   *Cache `json:"cache,omitempty"`
+	// ContentHash identifies the build context, Dockerfile and build args of the image
+	ContentHash string `json:"contentHash,omitempty"`
+	ReusedFrom  string `json:"reusedFrom,omitempty"`
+	// Tags maps every pushed tag to its digest
+	Tags map[string]string `json:"tags,omitempty"`
+	// Platforms maps platforms of a multi-platform image to their digests, Digest is the manifest list
+	Platforms map[string]string `json:"platforms,omitempty"`
+	Tests     []Test            `json:"tests,omitempty"`
+	Scan      *Scan             `json:"scan,omitempty"`
+}
+
+// Test is the result of a check run against an image.
+type Test struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Cache holds layer cache statistics of an image build.
+type Cache struct {
+	From   []string `json:"from"`
+	Export string   `json:"export,omitempty"`
+	Hits   int      `json:"hits"`
+	Steps  int      `json:"steps"`
+}
+
+// Scan is the vulnerability report of an image. Blocking counts findings at or above the
+// threshold which are not allowed.
+type Scan struct {
+	Image     string         `json:"image"`
+	Scanner   string         `json:"scanner"`
+	Threshold string         `json:"threshold"`
+	Blocking  int            `json:"blocking"`
+	Counts    map[string]int `json:"counts"`
+	Findings  []Finding      `json:"findings"`
+}
+
+type Finding struct {
+	Id           string `json:"id"`
+	Package      string `json:"package,omitempty"`
+	Version      string `json:"version,omitempty"`
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	Severity     string `json:"severity"`
+	Title        string `json:"title,omitempty"`
+	Allowed      bool   `json:"allowed,omitempty"`
+}
+
+func New(project, environment, version, commit string) *Report {
+	return &Report{
+		Project:     project,
+		Environment: environment,
+		Version:     version,
+		Commit:      commit,
+		Started:     time.Now(),
+		Images:      make([]*Image, 0),
+	}
+}
+
+// Image returns the report entry of an image creating it on first use.
+func (r *Report) Image(name string) *Image {
+	for _, image := range r.Images {
+		if image.Name == name {
+			return image
+		}
+	}
+
+	image := &Image{Name: name}
+	r.Images = append(r.Images, image)
+
+	return image
+}
+
+func (r *Report) Finish(steps []string, err error) {
+	r.Steps = steps
+	r.Finished = time.Now()
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+func (r *Report) Write(filename string) error {
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, contents, 0644)
+}
@@ -0,0 +1,96 @@
+package scan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const imagePlaceholder = "{{ .Image }}"
+
+const (
+	FormatJson  = "json"
+	FormatSarif = "sarif"
+)
+
+// commandScanner runs a scanner binary and parses its JSON or SARIF output from stdout.
+// Non-zero exit codes are ignored when the output can be parsed as scanners use them
+// to signal findings.
+type commandScanner struct {
+	name    string
+	command []string
+	format  string
+	logger  *log.Logger
+}
+
+func newCommandScanner(config project.Scan, name string, command []string, format string) (Scanner, error) {
+	if len(config.Command) > 0 {
+		command = config.Command
+	}
+
+	if config.Format != "" {
+		format = strings.ToLower(config.Format)
+	}
+
+	if format != FormatJson && format != FormatSarif {
+		return nil, errors.New(fmt.Sprintf("InvalidScanFormat(%s): expected json or sarif", config.Format))
+	}
+
+	return &commandScanner{
+		name:    name,
+		command: command,
+		format:  format,
+		logger: log.New(
+			os.Stdout, "[scan] ", log.Lmicroseconds,
+		),
+	}, nil
+}
+
+func (s *commandScanner) Name() string {
+	return s.name
+}
+
+func (s *commandScanner) Scan(reference string) ([]report.Finding, error) {
+	args := make([]string, 0)
+
+	for _, arg := range s.command[1:] {
+		args = append(args, strings.Replace(arg, imagePlaceholder, reference, -1))
+	}
+
+	s.logger.Printf("Running command %s %+v", s.command[0], args)
+
+	command := exec.Command(s.command[0], args...)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	runErr := command.Run()
+
+	var findings []report.Finding
+	var err error
+
+	switch s.format {
+	case FormatSarif:
+		findings, err = parseSarif(stdout.Bytes())
+	default:
+		findings, err = parseJson(stdout.Bytes())
+	}
+
+	if err != nil {
+		if runErr != nil {
+			return nil, errors.New(fmt.Sprintf("ScanFailed(%s): %s %s", reference, runErr, strings.TrimSpace(stderr.String())))
+		}
+
+		return nil, errors.New(fmt.Sprintf("InvalidScanOutput(%s): %s", reference, err))
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,220 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/wendigo/gcp-builder/report"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// trivyOutput is the JSON report of trivy image --format json.
+type trivyOutput struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+			Title            string `json:"Title"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// grypeOutput is the JSON report of grype --output json.
+type grypeOutput struct {
+	Matches []struct {
+		Vulnerability struct {
+			Id          string `json:"id"`
+			Severity    string `json:"severity"`
+			Description string `json:"description"`
+			Fix         struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+type sarifOutput struct {
+	Runs []struct {
+		Tool struct {
+			Driver struct {
+				Rules []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []struct {
+			RuleId  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// sarifRuleId matches rule ids of grype SARIF reports which append the package to the vulnerability id.
+var sarifRuleId = regexp.MustCompile(`^(CVE-\d{4}-\d+|GHSA(?:-[0-9a-z]{4}){3})-(.+)$`)
+
+type sarifRule struct {
+	Id               string `json:"id"`
+	ShortDescription struct {
+		Text string `json:"text"`
+	} `json:"shortDescription"`
+	Properties struct {
+		SecuritySeverity string `json:"security-severity"`
+	} `json:"properties"`
+}
+
+// parseJson reads trivy and grype JSON reports. Trivy before 0.20 reports a list of results.
+func parseJson(contents []byte) ([]report.Finding, error) {
+	if strings.HasPrefix(strings.TrimSpace(string(contents)), "[") {
+		legacy := trivyOutput{}
+
+		if err := json.Unmarshal(contents, &legacy.Results); err != nil {
+			return nil, err
+		}
+
+		return trivyFindings(legacy), nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return nil, err
+	}
+
+	findings := make([]report.Finding, 0)
+
+	if _, exists := fields["matches"]; exists {
+		output := grypeOutput{}
+
+		if err := json.Unmarshal(contents, &output); err != nil {
+			return nil, err
+		}
+
+		for _, match := range output.Matches {
+			findings = append(findings, report.Finding{
+				Id:           match.Vulnerability.Id,
+				Package:      match.Artifact.Name,
+				Version:      match.Artifact.Version,
+				FixedVersion: strings.Join(match.Vulnerability.Fix.Versions, ", "),
+				Severity:     match.Vulnerability.Severity,
+				Title:        match.Vulnerability.Description,
+			})
+		}
+
+		return findings, nil
+	}
+
+	if _, exists := fields["Results"]; !exists && fields["SchemaVersion"] == nil {
+		return nil, errors.New("unrecognized JSON report, expected trivy or grype output")
+	}
+
+	output := trivyOutput{}
+
+	if err := json.Unmarshal(contents, &output); err != nil {
+		return nil, err
+	}
+
+	return trivyFindings(output), nil
+}
+
+func trivyFindings(output trivyOutput) []report.Finding {
+	findings := make([]report.Finding, 0)
+
+	for _, result := range output.Results {
+		for _, vulnerability := range result.Vulnerabilities {
+			findings = append(findings, report.Finding{
+				Id:           vulnerability.VulnerabilityID,
+				Package:      vulnerability.PkgName,
+				Version:      vulnerability.InstalledVersion,
+				FixedVersion: vulnerability.FixedVersion,
+				Severity:     vulnerability.Severity,
+				Title:        vulnerability.Title,
+			})
+		}
+	}
+
+	return findings
+}
+
+// parseSarif reads SARIF reports taking severities from the security-severity (CVSS) property
+// of rules, falling back to result levels.
+func parseSarif(contents []byte) ([]report.Finding, error) {
+	output := sarifOutput{}
+
+	if err := json.Unmarshal(contents, &output); err != nil {
+		return nil, err
+	}
+
+	if len(output.Runs) == 0 {
+		return nil, errors.New("SARIF report without runs")
+	}
+
+	findings := make([]report.Finding, 0)
+
+	for _, run := range output.Runs {
+		rules := make(map[string]sarifRule)
+
+		for _, rule := range run.Tool.Driver.Rules {
+			rules[rule.Id] = rule
+		}
+
+		for _, result := range run.Results {
+			rule := rules[result.RuleId]
+
+			title := rule.ShortDescription.Text
+			if title == "" {
+				title = strings.SplitN(result.Message.Text, "\n", 2)[0]
+			}
+
+			finding := report.Finding{
+				Id:       result.RuleId,
+				Severity: sarifSeverity(rule.Properties.SecuritySeverity, result.Level),
+				Title:    title,
+			}
+
+			if matches := sarifRuleId.FindStringSubmatch(result.RuleId); matches != nil {
+				finding.Id = matches[1]
+				finding.Package = matches[2]
+			}
+
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings, nil
+}
+
+func sarifSeverity(score, level string) string {
+	if parsed, err := strconv.ParseFloat(score, 64); err == nil {
+		switch {
+		case parsed >= 9:
+			return SeverityCritical
+		case parsed >= 7:
+			return SeverityHigh
+		case parsed >= 4:
+			return SeverityMedium
+		case parsed > 0:
+			return SeverityLow
+		}
+	}
+
+	switch level {
+	case "error":
+		return SeverityHigh
+	case "warning":
+		return SeverityMedium
+	case "note":
+		return SeverityLow
+	}
+
+	return SeverityUnknown
+}
@@ -0,0 +1,172 @@
+package scan
+
+import (
+	"github.com/wendigo/gcp-builder/report"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contents
+}
+
+func assertFindings(t *testing.T, name string, findings []report.Finding, expected []report.Finding) {
+	if len(findings) != len(expected) {
+		t.Fatalf("%s: expected %d findings, got %d: %+v", name, len(expected), len(findings), findings)
+	}
+
+	for index := range expected {
+		if findings[index] != expected[index] {
+			t.Errorf("%s: expected finding %+v, got %+v", name, expected[index], findings[index])
+		}
+	}
+}
+
+func TestParseTrivyJson(t *testing.T) {
+	findings, err := parseJson(fixture(t, "trivy.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindings(t, "trivy", findings, []report.Finding{
+		{Id: "CVE-2023-5363", Package: "libssl3", Version: "3.1.3-r0", FixedVersion: "3.1.4-r0", Severity: "HIGH", Title: "openssl: Incorrect cipher key and IV length processing"},
+		{Id: "CVE-2023-5678", Package: "libcrypto3", Version: "3.1.3-r0", FixedVersion: "3.1.4-r1", Severity: "MEDIUM", Title: "openssl: Generating excessively long X9.42 DH keys or checking excessively long X9.42 DH keys or parameters may be very slow"},
+		{Id: "CVE-2023-44487", Package: "golang.org/x/net", Version: "v0.15.0", FixedVersion: "0.17.0", Severity: "CRITICAL", Title: "HTTP/2 Stream Cancellation Attack"},
+		{Id: "GHSA-m425-mq94-257g", Package: "google.golang.org/grpc", Version: "v1.58.2", FixedVersion: "1.58.3", Severity: "LOW", Title: "gRPC-Go HTTP/2 Rapid Reset vulnerability"},
+		{Id: "CVE-2023-99999", Package: "example.com/unrated", Version: "v0.1.0", Severity: "UNKNOWN"},
+	})
+}
+
+func TestParseLegacyTrivyJson(t *testing.T) {
+	legacy := `[
+  {
+    "Target": "app:1.0.0 (debian 9.13)",
+    "Type": "debian",
+    "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2021-3711", "PkgName": "libssl1.1", "InstalledVersion": "1.1.0l-1~deb9u3", "FixedVersion": "1.1.0l-1~deb9u4", "Severity": "CRITICAL", "Title": "openssl: SM2 Decryption Buffer Overflow"}
+    ]
+  },
+  {
+    "Target": "app/requirements.txt",
+    "Type": "pip",
+    "Vulnerabilities": null
+  }
+]`
+
+	findings, err := parseJson([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindings(t, "legacy trivy", findings, []report.Finding{
+		{Id: "CVE-2021-3711", Package: "libssl1.1", Version: "1.1.0l-1~deb9u3", FixedVersion: "1.1.0l-1~deb9u4", Severity: "CRITICAL", Title: "openssl: SM2 Decryption Buffer Overflow"},
+	})
+}
+
+func TestParseGrypeJson(t *testing.T) {
+	findings, err := parseJson(fixture(t, "grype.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindings(t, "grype", findings, []report.Finding{
+		{Id: "CVE-2023-38545", Package: "curl", Version: "8.3.0-r0", FixedVersion: "8.4.0-r0", Severity: "Critical", Title: "SOCKS5 heap buffer overflow"},
+		{Id: "CVE-2023-4039", Package: "libgcc", Version: "12.2.1_git20220924-r10", Severity: "Negligible", Title: "GCC stack protector bypass on AArch64"},
+		{Id: "GHSA-qppj-fm5r-hxr3", Package: "golang.org/x/net", Version: "v0.15.0", FixedVersion: "0.17.0, 0.18.0", Severity: "Moderate", Title: "swift-nio-http2 vulnerable to HTTP/2 Stream Cancellation Attack"},
+	})
+}
+
+func TestParseEmptyReports(t *testing.T) {
+	for _, contents := range []string{`{"SchemaVersion": 2, "ArtifactName": "app:1.0.0"}`, `{"matches": []}`, `[]`} {
+		findings, err := parseJson([]byte(contents))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", contents, err)
+			continue
+		}
+
+		if len(findings) != 0 {
+			t.Errorf("%s: expected no findings, got %+v", contents, findings)
+		}
+	}
+}
+
+func TestParseGrypeSarif(t *testing.T) {
+	findings, err := parseSarif(fixture(t, "grype.sarif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindings(t, "grype sarif", findings, []report.Finding{
+		{Id: "CVE-2023-5363", Package: "libssl3", Severity: "HIGH", Title: "CVE-2023-5363 high vulnerability for libssl3 package"},
+		{Id: "CVE-2023-44487", Package: "golang.org/x/net", Severity: "CRITICAL", Title: "CVE-2023-44487 high vulnerability for golang.org/x/net package"},
+		{Id: "GHSA-m425-mq94-257g", Package: "google.golang.org/grpc", Severity: "MEDIUM", Title: "GHSA-m425-mq94-257g high vulnerability for google.golang.org/grpc package"},
+		{Id: "CVE-2023-5678", Package: "libcrypto3", Severity: "MEDIUM", Title: "CVE-2023-5678 medium vulnerability for libcrypto3 package"},
+	})
+}
+
+func TestParseSarifWithoutRules(t *testing.T) {
+	sarif := `{"runs": [{"tool": {"driver": {"name": "scanner"}}, "results": [
+  {"ruleId": "CVE-2024-0001", "level": "error", "message": {"text": "first line\nsecond line"}},
+  {"ruleId": "CVE-2024-0002", "level": "warning", "message": {"text": "warning"}},
+  {"ruleId": "CVE-2024-0003", "level": "note", "message": {"text": "note"}},
+  {"ruleId": "custom-check", "level": "none", "message": {"text": "none"}}
+]}]}`
+
+	findings, err := parseSarif([]byte(sarif))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindings(t, "sarif levels", findings, []report.Finding{
+		{Id: "CVE-2024-0001", Severity: "HIGH", Title: "first line"},
+		{Id: "CVE-2024-0002", Severity: "MEDIUM", Title: "warning"},
+		{Id: "CVE-2024-0003", Severity: "LOW", Title: "note"},
+		{Id: "custom-check", Severity: "UNKNOWN", Title: "none"},
+	})
+}
+
+func TestSarifSeverity(t *testing.T) {
+	tests := []struct {
+		score    string
+		level    string
+		expected string
+	}{
+		{"10.0", "note", SeverityCritical},
+		{"9.0", "", SeverityCritical},
+		{"8.9", "", SeverityHigh},
+		{"7.0", "", SeverityHigh},
+		{"6.9", "", SeverityMedium},
+		{"4.0", "", SeverityMedium},
+		{"3.9", "error", SeverityLow},
+		{"0.1", "", SeverityLow},
+		{"0.0", "error", SeverityHigh},
+		{"high", "warning", SeverityMedium},
+		{"", "", SeverityUnknown},
+	}
+
+	for _, test := range tests {
+		if severity := sarifSeverity(test.score, test.level); severity != test.expected {
+			t.Errorf("score %q level %q: expected %s, got %s", test.score, test.level, test.expected, severity)
+		}
+	}
+}
+
+func TestParseInvalidReports(t *testing.T) {
+	for _, contents := range []string{``, `not json`, `{"foo": 1}`, `{"Results": "none"}`, `[{"Vulnerabilities": 1}]`} {
+		if findings, err := parseJson([]byte(contents)); err == nil {
+			t.Errorf("%q: expected JSON error, got %+v", contents, findings)
+		}
+	}
+
+	for _, contents := range []string{``, `not json`, `{"runs": []}`, `{"version": "2.1.0"}`} {
+		if findings, err := parseSarif([]byte(contents)); err == nil {
+			t.Errorf("%q: expected SARIF error, got %+v", contents, findings)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
+	"sort"
+	"strings"
+)
+
+const (
+	SeverityUnknown  = "UNKNOWN"
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+const defaultThreshold = SeverityCritical
+
+// Severities are ordered from the least severe.
+var Severities = []string{SeverityUnknown, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// Scanner finds vulnerabilities of an image available in the local Docker daemon.
+type Scanner interface {
+	Name() string
+	Scan(reference string) ([]report.Finding, error)
+}
+
+func New(config project.Scan) (Scanner, error) {
+	switch config.Scanner {
+	case "", "trivy":
+		return newCommandScanner(config, "trivy", []string{"trivy", "image", "--quiet", "--format", "json", imagePlaceholder}, FormatJson)
+	case "grype":
+		return newCommandScanner(config, "grype", []string{"grype", "--output", "json", imagePlaceholder}, FormatJson)
+	case "command":
+		if len(config.Command) == 0 {
+			return nil, errors.New("InvalidScanConfiguration: command scanner requires command")
+		}
+
+		return newCommandScanner(config, "command", nil, FormatJson)
+	default:
+		return nil, errors.New(fmt.Sprintf("UnknownScanner(%s): expected trivy, grype or command", config.Scanner))
+	}
+}
+
+// Evaluate builds the scan report marking allowed findings. Findings at or above the threshold
+// (CRITICAL by default) which are not allowed are blocking.
+func Evaluate(image, scanner string, findings []report.Finding, config project.Scan) (report.Scan, error) {
+	threshold := strings.ToUpper(config.Severity)
+	if threshold == "" {
+		threshold = defaultThreshold
+	}
+
+	if rank(threshold) == -1 {
+		return report.Scan{}, errors.New(fmt.Sprintf("InvalidSeverity(%s): expected one of %s", config.Severity, strings.Join(Severities, ", ")))
+	}
+
+	result := report.Scan{
+		Image:     image,
+		Scanner:   scanner,
+		Threshold: threshold,
+		Counts:    make(map[string]int),
+		Findings:  make([]report.Finding, 0),
+	}
+
+	for _, finding := range findings {
+		finding.Severity = normalizeSeverity(finding.Severity)
+		finding.Allowed = contains(config.Allow, finding.Id)
+
+		result.Counts[finding.Severity]++
+
+		if !finding.Allowed && rank(finding.Severity) >= rank(threshold) {
+			result.Blocking++
+		}
+
+		result.Findings = append(result.Findings, finding)
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		return rank(result.Findings[i].Severity) > rank(result.Findings[j].Severity)
+	})
+
+	return result, nil
+}
+
+func rank(severity string) int {
+	for index, known := range Severities {
+		if known == severity {
+			return index
+		}
+	}
+
+	return -1
+}
+
+func normalizeSeverity(severity string) string {
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+
+	switch severity {
+	case "NEGLIGIBLE":
+		return SeverityLow
+	case "MODERATE":
+		return SeverityMedium
+	}
+
+	if rank(severity) == -1 {
+		return SeverityUnknown
+	}
+
+	return severity
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package scan
+
+import (
+	"github.com/wendigo/gcp-builder/project"
+	"github.com/wendigo/gcp-builder/report"
+	"testing"
+)
+
+var testFindings = []report.Finding{
+	{Id: "CVE-2023-0001", Package: "zlib", Severity: "Medium"},
+	{Id: "CVE-2023-0002", Package: "openssl", Severity: "CRITICAL"},
+	{Id: "CVE-2023-0003", Package: "libgcc", Severity: "Negligible"},
+	{Id: "GHSA-aaaa-bbbb-cccc", Package: "golang.org/x/net", Severity: "Moderate"},
+	{Id: "CVE-2023-0004", Package: "curl", Severity: " high "},
+	{Id: "CVE-2023-0005", Package: "busybox", Severity: "unimportant"},
+	{Id: "CVE-2023-0006", Package: "musl", Severity: "critical"},
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    project.Scan
+		threshold string
+		blocking  int
+		allowed   []string
+	}{
+		{"default threshold", project.Scan{}, SeverityCritical, 2, nil},
+		{"lowercase threshold", project.Scan{Severity: "high"}, SeverityHigh, 3, nil},
+		{"medium threshold", project.Scan{Severity: "MEDIUM"}, SeverityMedium, 5, nil},
+		{"low threshold", project.Scan{Severity: "Low"}, SeverityLow, 6, nil},
+		{"unknown threshold", project.Scan{Severity: "UNKNOWN"}, SeverityUnknown, 7, nil},
+		{"allowed critical", project.Scan{Allow: []string{"CVE-2023-0002"}}, SeverityCritical, 1, []string{"CVE-2023-0002"}},
+		{"all critical allowed", project.Scan{Allow: []string{"CVE-2023-0002", "CVE-2023-0006"}}, SeverityCritical, 0, []string{"CVE-2023-0002", "CVE-2023-0006"}},
+		{"allowed below threshold", project.Scan{Severity: "HIGH", Allow: []string{"CVE-2023-0001", "CVE-2023-0004"}}, SeverityHigh, 2, []string{"CVE-2023-0001", "CVE-2023-0004"}},
+		{"allow list matches exact ids", project.Scan{Allow: []string{"cve-2023-0002", "CVE-2023"}}, SeverityCritical, 2, nil},
+	}
+
+	for _, test := range tests {
+		result, err := Evaluate("app", "trivy", testFindings, test.config)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+			continue
+		}
+
+		if result.Image != "app" || result.Scanner != "trivy" {
+			t.Errorf("%s: unexpected image %s and scanner %s", test.name, result.Image, result.Scanner)
+		}
+
+		if result.Threshold != test.threshold {
+			t.Errorf("%s: expected threshold %s, got %s", test.name, test.threshold, result.Threshold)
+		}
+
+		if result.Blocking != test.blocking {
+			t.Errorf("%s: expected %d blocking findings, got %d", test.name, test.blocking, result.Blocking)
+		}
+
+		allowed := make([]string, 0)
+
+		for _, finding := range result.Findings {
+			if finding.Allowed {
+				allowed = append(allowed, finding.Id)
+			}
+		}
+
+		if len(allowed) != len(test.allowed) {
+			t.Errorf("%s: expected allowed findings %v, got %v", test.name, test.allowed, allowed)
+			continue
+		}
+
+		for _, id := range test.allowed {
+			if !contains(allowed, id) {
+				t.Errorf("%s: expected %s to be allowed, got %v", test.name, id, allowed)
+			}
+		}
+	}
+}
+
+func TestEvaluateNormalizesAndSortsFindings(t *testing.T) {
+	result, err := Evaluate("app", "grype", testFindings, project.Scan{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		id       string
+		severity string
+	}{
+		{"CVE-2023-0002", SeverityCritical},
+		{"CVE-2023-0006", SeverityCritical},
+		{"CVE-2023-0004", SeverityHigh},
+		{"CVE-2023-0001", SeverityMedium},
+		{"GHSA-aaaa-bbbb-cccc", SeverityMedium},
+		{"CVE-2023-0003", SeverityLow},
+		{"CVE-2023-0005", SeverityUnknown},
+	}
+
+	if len(result.Findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %+v", len(expected), result.Findings)
+	}
+
+	for index, finding := range result.Findings {
+		if finding.Id != expected[index].id || finding.Severity != expected[index].severity {
+			t.Errorf("finding %d: expected %s (%s), got %s (%s)", index, expected[index].id, expected[index].severity, finding.Id, finding.Severity)
+		}
+	}
+
+	counts := map[string]int{SeverityCritical: 2, SeverityHigh: 1, SeverityMedium: 2, SeverityLow: 1, SeverityUnknown: 1}
+
+	if len(result.Counts) != len(counts) {
+		t.Errorf("expected counts %v, got %v", counts, result.Counts)
+	}
+
+	for severity, count := range counts {
+		if result.Counts[severity] != count {
+			t.Errorf("expected %d %s findings, got %d", count, severity, result.Counts[severity])
+		}
+	}
+
+	if testFindings[0].Severity != "Medium" {
+		t.Errorf("expected findings passed to Evaluate to be left unchanged, got %s", testFindings[0].Severity)
+	}
+}
+
+func TestEvaluateWithoutFindings(t *testing.T) {
+	result, err := Evaluate("app", "trivy", nil, project.Scan{Severity: "LOW"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Blocking != 0 || len(result.Findings) != 0 || len(result.Counts) != 0 {
+		t.Errorf("expected empty scan, got %+v", result)
+	}
+
+	if result.Findings == nil || result.Counts == nil {
+		t.Error("expected empty findings and counts instead of null in the run report")
+	}
+}
+
+func TestEvaluateInvalidSeverity(t *testing.T) {
+	for _, severity := range []string{"urgent", "NEGLIGIBLE", "HIGH+"} {
+		if _, err := Evaluate("app", "trivy", testFindings, project.Scan{Severity: severity}); err == nil {
+			t.Errorf("%s: expected invalid severity error", severity)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const ageHeader = "age-encryption.org/v1"
+
+// Keys used to decrypt secrets. Identities are never written to disk.
+type Keys struct {
+	AgeIdentities string
+	PgpPrivateKey string
+	PgpPassphrase string
+}
+
+// Decrypt decrypts an age (binary or armored) or PGP (binary or armored) encrypted file.
+// Errors never contain decrypted data.
+func Decrypt(ciphertext []byte, keys Keys) ([]byte, error) {
+	if isAge(ciphertext) {
+		return decryptAge(ciphertext, keys)
+	}
+
+	return decryptPgp(ciphertext, keys)
+}
+
+func isAge(ciphertext []byte) bool {
+	return bytes.HasPrefix(ciphertext, []byte(ageHeader)) || bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte(armor.Header))
+}
+
+func decryptAge(ciphertext []byte, keys Keys) ([]byte, error) {
+	if keys.AgeIdentities == "" {
+		return nil, errors.New("AgeKeyMissing")
+	}
+
+	identities, err := age.ParseIdentities(strings.NewReader(keys.AgeIdentities))
+	if err != nil {
+		return nil, errors.New("InvalidAgeKey")
+	}
+
+	var reader io.Reader = bytes.NewReader(ciphertext)
+
+	if bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte(armor.Header)) {
+		reader = armor.NewReader(bytes.NewReader(bytes.TrimSpace(ciphertext)))
+	}
+
+	plaintext, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, errors.New("AgeDecryptionFailed")
+	}
+
+	return ioutil.ReadAll(plaintext)
+}
+
+func decryptPgp(ciphertext []byte, keys Keys) ([]byte, error) {
+	if keys.PgpPrivateKey == "" {
+		return nil, errors.New("PgpKeyMissing")
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(keys.PgpPrivateKey))
+	if err != nil {
+		return nil, errors.New("InvalidPgpKey")
+	}
+
+	if err := unlockKeys(keyring, keys.PgpPassphrase); err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(ciphertext)
+
+	if bytes.HasPrefix(bytes.TrimSpace(ciphertext), []byte("-----BEGIN PGP MESSAGE-----")) {
+		block, err := pgparmor.Decode(bytes.NewReader(bytes.TrimSpace(ciphertext)))
+		if err != nil {
+			return nil, errors.New("InvalidPgpArmor")
+		}
+
+		reader = block.Body
+	}
+
+	message, err := openpgp.ReadMessage(reader, keyring, nil, nil)
+	if err != nil {
+		return nil, errors.New("PgpDecryptionFailed")
+	}
+
+	return ioutil.ReadAll(message.UnverifiedBody)
+}
+
+func unlockKeys(keyring openpgp.EntityList, passphrase string) error {
+	for _, entity := range keyring {
+		keys := []*packet.PrivateKey{entity.PrivateKey}
+
+		for _, subkey := range entity.Subkeys {
+			keys = append(keys, subkey.PrivateKey)
+		}
+
+		for _, key := range keys {
+			if key != nil && key.Encrypted {
+				if err := key.Decrypt([]byte(passphrase)); err != nil {
+					return errors.New("PgpPassphraseInvalid")
+				}
+			}
+		}
+	}
+
+	return nil
+}
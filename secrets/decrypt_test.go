@@ -0,0 +1,185 @@
+package secrets
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"io"
+	"testing"
+)
+
+const plaintext = "s3cr3t\x00binary"
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func encryptAge(t *testing.T, recipient age.Recipient, armored bool) []byte {
+	buffer := &bytes.Buffer{}
+	var out io.WriteCloser = nopCloser{buffer}
+
+	if armored {
+		out = armor.NewWriter(buffer)
+	}
+
+	writer, err := age.Encrypt(out, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestDecryptAge(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, armored := range []bool{false, true} {
+		ciphertext := encryptAge(t, identity.Recipient(), armored)
+
+		decrypted, err := Decrypt(ciphertext, Keys{AgeIdentities: "# created: today\n" + identity.String() + "\n"})
+		if err != nil || string(decrypted) != plaintext {
+			t.Errorf("armored %t: expected %q, got %q (%v)", armored, plaintext, decrypted, err)
+		}
+
+		if _, err := Decrypt(ciphertext, Keys{}); err == nil || err.Error() != "AgeKeyMissing" {
+			t.Errorf("armored %t: expected AgeKeyMissing, got %v", armored, err)
+		}
+
+		if _, err := Decrypt(ciphertext, Keys{AgeIdentities: other.String()}); err == nil || err.Error() != "AgeDecryptionFailed" {
+			t.Errorf("armored %t: expected AgeDecryptionFailed, got %v", armored, err)
+		}
+	}
+
+	if _, err := Decrypt(encryptAge(t, identity.Recipient(), false), Keys{AgeIdentities: "not a key"}); err == nil || err.Error() != "InvalidAgeKey" {
+		t.Errorf("expected InvalidAgeKey, got %v", err)
+	}
+}
+
+func newPgpKey(t *testing.T, passphrase string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("gcp-builder", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if passphrase != "" {
+		if err := entity.EncryptPrivateKeys([]byte(passphrase), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+
+	writer, err := pgparmor.Encode(buffer, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entity.SerializePrivateWithoutSigning(writer, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return entity, buffer.String()
+}
+
+func encryptPgp(t *testing.T, entity *openpgp.Entity, armored bool) []byte {
+	buffer := &bytes.Buffer{}
+	var out io.WriteCloser = nopCloser{buffer}
+
+	if armored {
+		armorWriter, err := pgparmor.Encode(buffer, "PGP MESSAGE", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out = armorWriter
+	}
+
+	writer, err := openpgp.Encrypt(out, []*openpgp.Entity{entity}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestDecryptPgp(t *testing.T) {
+	entity, key := newPgpKey(t, "")
+	_, other := newPgpKey(t, "")
+
+	for _, armored := range []bool{false, true} {
+		ciphertext := encryptPgp(t, entity, armored)
+
+		decrypted, err := Decrypt(ciphertext, Keys{PgpPrivateKey: key})
+		if err != nil || string(decrypted) != plaintext {
+			t.Errorf("armored %t: expected %q, got %q (%v)", armored, plaintext, decrypted, err)
+		}
+
+		if _, err := Decrypt(ciphertext, Keys{}); err == nil || err.Error() != "PgpKeyMissing" {
+			t.Errorf("armored %t: expected PgpKeyMissing, got %v", armored, err)
+		}
+
+		if _, err := Decrypt(ciphertext, Keys{PgpPrivateKey: other}); err == nil || err.Error() != "PgpDecryptionFailed" {
+			t.Errorf("armored %t: expected PgpDecryptionFailed, got %v", armored, err)
+		}
+	}
+
+	if _, err := Decrypt(encryptPgp(t, entity, true), Keys{PgpPrivateKey: "not a key"}); err == nil || err.Error() != "InvalidPgpKey" {
+		t.Errorf("expected InvalidPgpKey, got %v", err)
+	}
+}
+
+func TestDecryptPgpWithPassphrase(t *testing.T) {
+	entity, key := newPgpKey(t, "passphrase")
+	ciphertext := encryptPgp(t, entity, true)
+
+	decrypted, err := Decrypt(ciphertext, Keys{PgpPrivateKey: key, PgpPassphrase: "passphrase"})
+	if err != nil || string(decrypted) != plaintext {
+		t.Errorf("expected %q, got %q (%v)", plaintext, decrypted, err)
+	}
+
+	if _, err := Decrypt(ciphertext, Keys{PgpPrivateKey: key, PgpPassphrase: "wrong"}); err == nil || err.Error() != "PgpPassphraseInvalid" {
+		t.Errorf("expected PgpPassphraseInvalid, got %v", err)
+	}
+}
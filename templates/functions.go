@@ -0,0 +1,247 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wendigo/gcp-builder/project"
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Functions returns the function library available in deployment templates, Dockerfiles
+// and notifications. The map can be passed to both text/template and html/template.
+func Functions() map[string]interface{} {
+	return map[string]interface{}{
+		"upper":         strings.ToUpper,
+		"lower":         strings.ToLower,
+		"title":         title,
+		"trim":          strings.TrimSpace,
+		"replace":       replace,
+		"quote":         quote,
+		"squote":        squote,
+		"indent":        indent,
+		"nindent":       nindent,
+		"toYaml":        toYaml,
+		"toJson":        toJson,
+		"b64enc":        b64enc,
+		"b64dec":        b64dec,
+		"sha256sum":     sha256sum,
+		"default":       defaultValue,
+		"required":      required,
+		"now":           time.Now,
+		"date":          date,
+		"semverCompare": semverCompare,
+	}
+}
+
+// title upper cases the first letter of every whitespace separated word.
+func title(value string) string {
+	runes := []rune(value)
+
+	for i := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) {
+			runes[i] = unicode.ToTitle(runes[i])
+		}
+	}
+
+	return string(runes)
+}
+
+func replace(old, new, value string) string {
+	return strings.Replace(value, old, new, -1)
+}
+
+func quote(value interface{}) string {
+	return fmt.Sprintf("%q", toString(value))
+}
+
+func squote(value interface{}) string {
+	return fmt.Sprintf("'%s'", strings.Replace(toString(value), "'", "''", -1))
+}
+
+func indent(spaces int, value string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.Replace(value, "\n", "\n"+padding, -1)
+}
+
+func nindent(spaces int, value string) string {
+	return "\n" + indent(spaces, value)
+}
+
+func toYaml(value interface{}) (string, error) {
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func toJson(value interface{}) (string, error) {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func b64enc(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func b64dec(value string) (string, error) {
+	out, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func sha256sum(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// defaultValue returns the given default when the value is empty: {{ .Variable "x" | default "y" }}
+func defaultValue(defaultValue interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || isEmpty(value[0]) {
+		return defaultValue
+	}
+
+	return value[0]
+}
+
+func required(message string, value interface{}) (interface{}, error) {
+	if isEmpty(value) {
+		return nil, errors.New(message)
+	}
+
+	return value, nil
+}
+
+// date formats time using Go reference layout: {{ now | date "2006-01-02" }}
+func date(layout string, value interface{}) (string, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		return t.Format(layout), nil
+	case int64:
+		return time.Unix(t, 0).UTC().Format(layout), nil
+	case int:
+		return time.Unix(int64(t), 0).UTC().Format(layout), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return "", err
+		}
+
+		return parsed.Format(layout), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("InvalidDate(%v)", value))
+}
+
+// semverCompare checks a version against space or comma separated constraints which all
+// have to be satisfied: {{ if semverCompare ">=1.2.0 <2.0.0" .Version }}. Snapshot versions
+// satisfy no constraint.
+func semverCompare(constraints string, value interface{}) (bool, error) {
+	version, ok := value.(project.Version)
+
+	if !ok {
+		parsed, err := project.ParseVersion(strings.TrimPrefix(toString(value), "v"))
+		if err != nil {
+			return false, err
+		}
+
+		version = parsed
+	}
+
+	result := !version.IsSnapshot()
+
+	for _, constraint := range strings.Fields(strings.Replace(constraints, ",", " ", -1)) {
+		satisfied, err := satisfies(version, constraint)
+		if err != nil {
+			return false, err
+		}
+
+		result = result && satisfied
+	}
+
+	return result, nil
+}
+
+func satisfies(version project.Version, constraint string) (bool, error) {
+	operator := strings.TrimRight(constraint, "0123456789.-+abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	expected, err := project.ParseVersion(strings.TrimPrefix(strings.TrimPrefix(constraint, operator), "v"))
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("InvalidConstraint(%s)", constraint))
+	}
+
+	result := version.Compare(expected)
+
+	switch operator {
+	case "", "=", "==":
+		return result == 0, nil
+	case "!=":
+		return result != 0, nil
+	case ">":
+		return result > 0, nil
+	case ">=":
+		return result >= 0, nil
+	case "<":
+		return result < 0, nil
+	case "<=":
+		return result <= 0, nil
+	}
+
+	return false, errors.New(fmt.Sprintf("InvalidConstraint(%s)", constraint))
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+
+	return false
+}
@@ -0,0 +1,294 @@
+package templates
+
+import (
+	"bytes"
+	"github.com/wendigo/gcp-builder/project"
+	"testing"
+	"text/template"
+	"time"
+)
+
+type functionTest struct {
+	template string
+	expected string
+}
+
+func render(template string, data interface{}) (string, error) {
+	tmpl, err := newTemplate().Parse(template)
+	if err != nil {
+		return "", err
+	}
+
+	buffer := &bytes.Buffer{}
+	err = tmpl.Execute(buffer, data)
+
+	return buffer.String(), err
+}
+
+func newTemplate() *template.Template {
+	return template.New("test").Funcs(Functions())
+}
+
+func runFunctionTests(t *testing.T, tests []functionTest, data interface{}) {
+	for _, test := range tests {
+		rendered, err := render(test.template, data)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.template, err)
+			continue
+		}
+
+		if rendered != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.template, test.expected, rendered)
+		}
+	}
+}
+
+func TestStringFunctions(t *testing.T) {
+	runFunctionTests(t, []functionTest{
+		{`{{ "Hello" | upper }}`, "HELLO"},
+		{`{{ "Hello" | lower }}`, "hello"},
+		{`{{ "hello wORLD  twice" | title }}`, "Hello WORLD  Twice"},
+		{`{{ "żółw\tłódź" | title }}`, "Żółw\tŁódź"},
+		{`{{ "" | title }}`, ""},
+		{`{{ "  padded\n" | trim }}`, "padded"},
+		{`{{ "a-b-c" | replace "-" "_" }}`, "a_b_c"},
+	}, nil)
+}
+
+func TestQuote(t *testing.T) {
+	runFunctionTests(t, []functionTest{
+		{`{{ "value" | quote }}`, `"value"`},
+		{`{{ "say \"hi\"" | quote }}`, `"say \"hi\""`},
+		{`{{ "line\nbreak" | quote }}`, `"line\nbreak"`},
+		{`{{ 42 | quote }}`, `"42"`},
+		{`{{ .Missing | quote }}`, `""`},
+		{`{{ .Version | quote }}`, `"1.2.3"`},
+		{`{{ "value" | squote }}`, `'value'`},
+		{`{{ "it's" | squote }}`, `'it''s'`},
+		{`{{ true | squote }}`, `'true'`},
+	}, map[string]interface{}{"Missing": nil, "Version": mustParseVersion(t, "1.2.3")})
+}
+
+func TestIndent(t *testing.T) {
+	runFunctionTests(t, []functionTest{
+		{`{{ "a" | indent 2 }}`, "  a"},
+		{`{{ "a\nb" | indent 4 }}`, "    a\n    b"},
+		{`{{ "a" | indent 0 }}`, "a"},
+		{`{{ "" | indent 2 }}`, "  "},
+		{`key:{{ "a: 1\nb: 2" | nindent 2 }}`, "key:\n  a: 1\n  b: 2"},
+		{`{{ "a" | nindent 0 }}`, "\na"},
+	}, nil)
+}
+
+func TestSerialization(t *testing.T) {
+	data := map[string]interface{}{
+		"Map":    map[string]interface{}{"b": []string{"x", "y"}, "a": 1},
+		"List":   []int{1, 2},
+		"String": "text: with colon",
+		"Nil":    nil,
+	}
+
+	runFunctionTests(t, []functionTest{
+		{`{{ .Map | toYaml }}`, "a: 1\nb:\n- x\n- \"y\""},
+		{`{{ .List | toYaml }}`, "- 1\n- 2"},
+		{`{{ .String | toYaml }}`, "'text: with colon'"},
+		{`{{ .Nil | toYaml }}`, "null"},
+		{`spec:{{ .Map | toYaml | nindent 2 }}`, "spec:\n  a: 1\n  b:\n  - x\n  - \"y\""},
+		{`{{ .Map | toJson }}`, `{"a":1,"b":["x","y"]}`},
+		{`{{ .List | toJson }}`, `[1,2]`},
+		{`{{ .String | toJson }}`, `"text: with colon"`},
+		{`{{ .Nil | toJson }}`, `null`},
+	}, data)
+
+	if _, err := render(`{{ .Channel | toJson }}`, map[string]interface{}{"Channel": make(chan int)}); err == nil {
+		t.Error("expected error serializing a channel to JSON")
+	}
+}
+
+func TestBase64(t *testing.T) {
+	runFunctionTests(t, []functionTest{
+		{`{{ "secret" | b64enc }}`, "c2VjcmV0"},
+		{`{{ "" | b64enc }}`, ""},
+		{`{{ "c2VjcmV0" | b64dec }}`, "secret"},
+		{`{{ "ż" | b64enc | b64dec }}`, "ż"},
+	}, nil)
+
+	if _, err := render(`{{ "not base64!" | b64dec }}`, nil); err == nil {
+		t.Error("expected error decoding invalid base64")
+	}
+}
+
+func TestSha256sum(t *testing.T) {
+	runFunctionTests(t, []functionTest{
+		{`{{ "" | sha256sum }}`, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{`{{ "abc" | sha256sum }}`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}, nil)
+}
+
+func TestDefault(t *testing.T) {
+	data := map[string]interface{}{
+		"Empty":   "",
+		"Value":   "set",
+		"Zero":    0,
+		"Number":  7,
+		"False":   false,
+		"List":    []string{},
+		"Map":     map[string]string{"a": "b"},
+		"Nothing": nil,
+	}
+
+	runFunctionTests(t, []functionTest{
+		{`{{ .Empty | default "fallback" }}`, "fallback"},
+		{`{{ .Value | default "fallback" }}`, "set"},
+		{`{{ .Zero | default 5 }}`, "5"},
+		{`{{ .Number | default 5 }}`, "7"},
+		{`{{ .False | default true }}`, "true"},
+		{`{{ .List | default "none" }}`, "none"},
+		{`{{ .Map | default "none" | len }}`, "1"},
+		{`{{ .Nothing | default "none" }}`, "none"},
+		{`{{ .Missing | default "none" }}`, "none"},
+		{`{{ default "none" }}`, "none"},
+	}, data)
+}
+
+func TestRequired(t *testing.T) {
+	runFunctionTests(t, []functionTest{
+		{`{{ "value" | required "value is required" }}`, "value"},
+		{`{{ 1 | required "number is required" }}`, "1"},
+	}, nil)
+
+	for _, template := range []string{
+		`{{ "" | required "value is required" }}`,
+		`{{ .Missing | required "value is required" }}`,
+		`{{ 0 | required "value is required" }}`,
+	} {
+		_, err := render(template, map[string]interface{}{})
+
+		if err == nil {
+			t.Errorf("%s: expected error", template)
+		} else if !bytes.Contains([]byte(err.Error()), []byte("value is required")) {
+			t.Errorf("%s: expected error with message, got %s", template, err)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	moment := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	runFunctionTests(t, []functionTest{
+		{`{{ .Time | date "2006-01-02" }}`, "2018-03-04"},
+		{`{{ .Pointer | date "15:04:05" }}`, "05:06:07"},
+		{`{{ .Unix | date "2006-01-02T15:04:05Z07:00" }}`, "2018-03-04T05:06:07Z"},
+		{`{{ .Int | date "2006" }}`, "2018"},
+		{`{{ "2018-03-04T05:06:07Z" | date "Jan 2, 2006" }}`, "Mar 4, 2018"},
+	}, map[string]interface{}{
+		"Time":    moment,
+		"Pointer": &moment,
+		"Unix":    moment.Unix(),
+		"Int":     int(moment.Unix()),
+	})
+
+	if rendered, err := render(`{{ now | date "2006" }}`, nil); err != nil || rendered != time.Now().Format("2006") {
+		t.Errorf("expected current year, got %q (%v)", rendered, err)
+	}
+
+	for _, template := range []string{`{{ "yesterday" | date "2006" }}`, `{{ 1.5 | date "2006" }}`} {
+		if _, err := render(template, nil); err == nil {
+			t.Errorf("%s: expected error", template)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	tests := []struct {
+		constraints string
+		version     string
+		expected    bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"=1.2.3", "1.2.3", true},
+		{"==1.2.3", "1.2.4", false},
+		{"!=1.2.3", "1.2.4", true},
+		{"!=1.2.3", "1.2.3", false},
+		{">1.2.3", "1.2.4", true},
+		{">1.2.3", "1.2.3", false},
+		{">=1.2.3", "1.2.3", true},
+		{">=1.2.3", "1.2.2", false},
+		{"<2.0.0", "1.9.9", true},
+		{"<2.0.0", "2.0.0", false},
+		{"<=2.0.0", "2.0.0", true},
+		{"<=2.0.0", "2.0.1", false},
+		{">=1.2.0 <2.0.0", "1.5.0", true},
+		{">=1.2.0, <2.0.0", "2.0.0", false},
+		{">=v1.2.0", "v1.2.0", true},
+		{"<2.0.0", "2.0.0-rc.1", true},
+		{">=2.0.0", "2.0.0-rc.1", false},
+		{"=1.2.3", "1.2.3+build.1", true},
+	}
+
+	for _, test := range tests {
+		satisfied, err := semverCompare(test.constraints, test.version)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error %s", test.version, test.constraints, err)
+			continue
+		}
+
+		if satisfied != test.expected {
+			t.Errorf("%s %s: expected %t, got %t", test.version, test.constraints, test.expected, satisfied)
+		}
+	}
+
+	runFunctionTests(t, []functionTest{
+		{`{{ if semverCompare ">=1.2.0 <2.0.0" .Version }}yes{{ else }}no{{ end }}`, "yes"},
+	}, map[string]interface{}{"Version": mustParseVersion(t, "1.5.0")})
+}
+
+func TestSemverCompareInvalid(t *testing.T) {
+	tests := []struct {
+		constraints string
+		version     string
+	}{
+		{"~1.2.0", "1.2.3"},
+		{"^1.2.0", "1.2.3"},
+		{">=1.2", "1.2.3"},
+		{"=>1.2.3", "1.2.3"},
+		{">=1.2.0 <two", "1.2.3"},
+		{">=1.2.0", "1.2"},
+		{">=1.2.0", "master-snapshot"},
+	}
+
+	for _, test := range tests {
+		if _, err := semverCompare(test.constraints, test.version); err == nil {
+			t.Errorf("%s %s: expected error", test.version, test.constraints)
+		}
+	}
+}
+
+func TestSemverCompareSnapshot(t *testing.T) {
+	snapshot := project.NewSnapshotVersion("master", "master-snapshot")
+
+	for _, constraints := range []string{">=0.0.0", "<1.0.0", "!=1.0.0"} {
+		satisfied, err := semverCompare(constraints, snapshot)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", constraints, err)
+		}
+
+		if satisfied {
+			t.Errorf("%s: expected snapshot version to satisfy no constraint", constraints)
+		}
+	}
+
+	if _, err := semverCompare("~1.0.0", snapshot); err == nil {
+		t.Error("expected error for invalid constraint with snapshot version")
+	}
+}
+
+func mustParseVersion(t *testing.T, value string) project.Version {
+	version, err := project.ParseVersion(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return version
+}